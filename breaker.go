@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops collections from calling RGW for a cooldown period once
+// threshold collections in a row have failed; after the cooldown a single trial
+// collection is let through, closing the breaker on success and reopening it on
+// failure, while the others keep being held back until it is over
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// Whether the trial collection after the cooldown is running
+	trial bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a collection may call RGW now; once the cooldown is over
+// only the first caller is allowed, as the trial, until its outcome is recorded
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	if b.failures >= b.threshold {
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// open reports whether collections are currently held back
+func (b *circuitBreaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil) || b.trial
+}
+
+// release ends a trial without counting its outcome, so that the next collection
+// becomes the trial
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// record counts the outcome of a collection; it reports whether the breaker has
+// just opened and whether it has just closed after being open
+func (b *circuitBreaker) record(ok bool) (opened, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		closed = b.failures >= b.threshold
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return false, closed
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		return b.failures == b.threshold, false
+	}
+	return false, false
+}
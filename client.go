@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/ceph/go-ceph/rgw/admin"
+	"golang.org/x/time/rate"
+)
+
+// defaultAdminPath — admin API prefix hard-coded in go-ceph
+const defaultAdminPath = "/admin"
+
+// newHTTPClient builds the HTTP client used for admin API calls and returns its
+// transport for endpoint statistics; discovery, when not nil, replaces the
+// endpoint host on every request
+func newHTTPClient(cfg Config, discovery *gatewayDiscovery) (*http.Client, *adminTransport) {
+	dialer := &net.Dialer{
+		Timeout:   cfg.HTTPDialTimeout,
+		KeepAlive: cfg.HTTPKeepAlive,
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.Insecure,
+		MinVersion:         uint16(cfg.TLSMinVersion),
+		CipherSuites:       cfg.TLSCipherSuites,
+	}
+	if discovery != nil {
+		// Discovered candidates may be bare IPs; verify the certificate against the published name
+		tlsConfig.ServerName = discovery.serverName()
+	}
+
+	var endpoints []*url.URL
+	for _, endpoint := range cfg.Endpoints {
+		if u, err := url.Parse(endpoint); err == nil {
+			endpoints = append(endpoints, u)
+		}
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPIdleConnTimeout,
+		TLSClientConfig:     tlsConfig,
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RGWRateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RGWRateLimit), max(cfg.RGWRateBurst, 1))
+	}
+
+	admin := &adminTransport{
+		base:      transport,
+		adminPath: cfg.AdminPath,
+		host:      cfg.HostHeader,
+		userAgent: cfg.UserAgent,
+		headers:   cfg.ExtraHeaders,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		discovery: discovery,
+		endpoints: endpoints,
+		limiter:   limiter,
+	}
+	return &http.Client{Timeout: cfg.HTTPTimeout, Transport: admin}, admin
+}
+
+// adminTransport rewrites admin API requests built by go-ceph before they hit the wire
+type adminTransport struct {
+	base      http.RoundTripper
+	adminPath string
+	host      string
+	userAgent string
+	headers   http.Header
+	accessKey string
+	secretKey string
+	discovery *gatewayDiscovery
+
+	// Token bucket shared by every request sent to the cluster, nil when unlimited
+	limiter *rate.Limiter
+
+	// Endpoints of the cluster in configured order; next rotates the first one tried
+	endpoints []*url.URL
+	next      atomic.Uint32
+
+	// Per-endpoint health and latency, keyed by host:port
+	mu    sync.Mutex
+	stats map[string]*endpointStats
+}
+
+// RoundTrip implements http.RoundTripper; with several endpoints configured, requests
+// are spread across them round-robin and connection errors and 5xx responses are
+// retried against the next one, trying endpoints that failed recently last
+func (t *adminTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.endpoints) < 2 {
+		return t.send(req, nil)
+	}
+
+	var healthy, degraded []*url.URL
+	for _, endpoint := range t.endpoints {
+		if t.healthy(endpoint.Host) {
+			healthy = append(healthy, endpoint)
+		} else {
+			degraded = append(degraded, endpoint)
+		}
+	}
+	order := make([]*url.URL, 0, len(t.endpoints))
+	if len(healthy) > 0 {
+		first := int(t.next.Add(1) % uint32(len(healthy)))
+		order = append(order, healthy[first:]...)
+		order = append(order, healthy[:first]...)
+	}
+	order = append(order, degraded...)
+
+	var resp *http.Response
+	var err error
+	for i, endpoint := range order {
+		resp, err = t.send(req, endpoint)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+		if req.Context().Err() != nil || i == len(order)-1 {
+			break
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// send rewrites one attempt of req, pointed at target when not nil, and sends it
+func (t *adminTransport) send(req *http.Request, target *url.URL) (*http.Response, error) {
+	if t.limiter != nil {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return nil, fmt.Errorf("admin API rate limit: %w", err)
+		}
+	}
+	countAPICall(req.Context())
+	r := req.Clone(req.Context())
+	for name, values := range t.headers {
+		r.Header[name] = values
+	}
+	if t.userAgent != "" {
+		r.Header.Set("User-Agent", t.userAgent)
+	}
+
+	resign := false
+	if t.adminPath != defaultAdminPath {
+		if rest, ok := strings.CutPrefix(r.URL.Path, defaultAdminPath); ok {
+			r.URL.Path = t.adminPath + rest
+			r.URL.RawPath = ""
+			resign = true
+		}
+	}
+	if target != nil && (target.Host != r.URL.Host || target.Scheme != r.URL.Scheme) {
+		r.URL.Scheme = target.Scheme
+		r.URL.Host = target.Host
+		r.Host = target.Host
+		resign = true
+	}
+	if t.discovery != nil {
+		if endpoint := t.discovery.endpoint(); endpoint != "" {
+			r.URL.Host = endpoint
+			r.Host = t.discovery.hostHeader(endpoint)
+			resign = true
+		}
+	}
+	if t.host != "" {
+		r.Host = t.host
+		resign = true
+	}
+
+	// go-ceph signed the original request, so sign the rewritten one again
+	if resign {
+		if err := signRequest(r.Context(), r, t.accessKey, t.secretKey); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(r)
+	ok := err == nil && resp.StatusCode < http.StatusInternalServerError
+	t.observe(r.URL.Host, time.Since(start), ok)
+	if ok {
+		servedBy(r.Context(), r.URL.Host)
+	}
+	return resp, err
+}
+
+// endpointRecheck — how long an endpoint that failed is tried only as a last resort
+const endpointRecheck = 30 * time.Second
+
+// endpointStats — health and latency of one RGW endpoint as seen by the transport
+type endpointStats struct {
+	up          bool
+	lastFailure time.Time
+	requests    uint64
+	seconds     float64
+}
+
+// observe records the outcome of one request sent to host
+func (t *adminTransport) observe(host string, d time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stats == nil {
+		t.stats = make(map[string]*endpointStats)
+	}
+	st, exists := t.stats[host]
+	if !exists {
+		st = &endpointStats{}
+		t.stats[host] = st
+	}
+	st.up = ok
+	if !ok {
+		st.lastFailure = time.Now()
+	}
+	st.requests++
+	st.seconds += d.Seconds()
+}
+
+// healthy reports whether host answered its last request or failed long enough ago to retry
+func (t *adminTransport) healthy(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.stats[host]
+	return !ok || st.up || time.Since(st.lastFailure) > endpointRecheck
+}
+
+// endpointStats returns a copy of the per-endpoint statistics
+func (t *adminTransport) endpointStats() map[string]endpointStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]endpointStats, len(t.stats))
+	for host, st := range t.stats {
+		out[host] = *st
+	}
+	return out
+}
+
+// adminGet performs a signed GET against an admin API resource go-ceph does not wrap
+// and decodes the JSON response into out
+func adminGet(ctx context.Context, api *admin.API, path string, args url.Values, out any) error {
+	return adminStream(ctx, api, path, args, func(dec *json.Decoder) error {
+		return dec.Decode(out)
+	})
+}
+
+// adminStream performs a signed GET against an admin API resource and lets decode
+// read the JSON response as it arrives instead of buffering it whole
+func adminStream(ctx context.Context, api *admin.API, path string, args url.Values, decode func(dec *json.Decoder) error) error {
+	if args == nil {
+		args = url.Values{}
+	}
+	args.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api.Endpoint+defaultAdminPath+path+"?"+args.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if err := signRequest(ctx, req, api.AccessKey, api.SecretKey); err != nil {
+		return err
+	}
+
+	resp, err := api.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := decode(json.NewDecoder(resp.Body)); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeArray calls fn for every element of the JSON array at the decoder's
+// position; fn decodes the element itself
+func decodeArray(dec *json.Decoder, fn func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// decodeObject calls fn with the key of every member of the JSON object at the
+// decoder's position; fn decodes or skips the value
+func decodeObject(dec *json.Decoder, fn func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// expectDelim consumes the next JSON token, which must be delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %q, got %v", delim, tok)
+	}
+	return nil
+}
+
+// metadataPage — one page of an admin metadata listing requested with max-entries
+type metadataPage struct {
+	Keys      []string `json:"keys"`
+	Truncated bool     `json:"truncated"`
+	Marker    string   `json:"marker"`
+}
+
+// listMetadata returns the keys of a metadata section such as "user", fetched in
+// pages of pageSize entries that follow the marker returned by RGW
+func listMetadata(ctx context.Context, api *admin.API, section string, pageSize int) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		args := url.Values{"max-entries": {strconv.Itoa(pageSize)}}
+		if marker != "" {
+			args.Set("marker", marker)
+		}
+		var page metadataPage
+		if err := adminGet(ctx, api, "/metadata/"+section, args, &page); err != nil {
+			return nil, err
+		}
+		keys = append(keys, page.Keys...)
+		if !page.Truncated || page.Marker == "" || page.Marker == marker {
+			return keys, nil
+		}
+		marker = page.Marker
+	}
+}
+
+// signRequest signs req with AWS SigV4 exactly like go-ceph does
+func signRequest(ctx context.Context, req *http.Request, accessKey, secretKey string) error {
+	creds, err := credentials.NewStaticCredentialsProvider(accessKey, secretKey, "").Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Del("Authorization")
+	req.Header.Del("X-Amz-Date")
+	return v4.NewSigner().SignHTTP(ctx, creds, req, "UNSIGNED-PAYLOAD", "s3", "default", time.Now())
+}
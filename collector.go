@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"log/slog"
-	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ceph/go-ceph/rgw/admin"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
 )
 
 // usageMetricKey — unique key for usage metric aggregation
@@ -21,11 +28,159 @@ type usageMetricValues struct {
 	ops, successfulOps, bytesSent, bytesReceived float64
 }
 
+// plus returns the sum of v and o
+func (v usageMetricValues) plus(o usageMetricValues) usageMetricValues {
+	return usageMetricValues{
+		ops:           v.ops + o.ops,
+		successfulOps: v.successfulOps + o.successfulOps,
+		bytesSent:     v.bytesSent + o.bytesSent,
+		bytesReceived: v.bytesReceived + o.bytesReceived,
+	}
+}
+
+// usageAggregate — usage metric values by unique key
+type usageAggregate map[usageMetricKey]*usageMetricValues
+
+// add sums v into the values of key
+func (a usageAggregate) add(key usageMetricKey, v usageMetricValues) {
+	sum, ok := a[key]
+	if !ok {
+		sum = &usageMetricValues{}
+		a[key] = sum
+	}
+	sum.ops += v.ops
+	sum.successfulOps += v.successfulOps
+	sum.bytesSent += v.bytesSent
+	sum.bytesReceived += v.bytesReceived
+}
+
+// merge sums the values of other into a
+func (a usageAggregate) merge(other usageAggregate) {
+	for key, v := range other {
+		a.add(key, *v)
+	}
+}
+
 // RADOSGWCollector implements prometheus.Collector
 type RADOSGWCollector struct {
-	client *admin.API
-	store  string
-	logger *slog.Logger
+	client    *admin.API
+	transport *adminTransport
+	store     string
+	logger    *slog.Logger
+
+	// Set once a collection has completed a successful RGW round-trip
+	ready atomic.Bool
+
+	// When set, only the elected replica collects; standbys emit just the leader gauge
+	leader     *leaderElector
+	leaderDesc *prometheus.Desc
+
+	// This replica exports only users whose uid hashes to shardIndex modulo shardTotal
+	shardIndex uint32
+	shardTotal uint32
+
+	// Internals of the most recent finished collection, for /debug/scrape
+	lastReport atomic.Pointer[scrapeReport]
+
+	// In-flight collections derive their context from ctx, which Shutdown cancels
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+
+	// Enabled sub-collectors in collection order
+	collectors []subCollector
+
+	// radosgw_up reports 0 only after downAfter failed collections in a row;
+	// failures and failStreak count them regardless
+	downAfter      int
+	failures       atomic.Uint64
+	failStreak     atomic.Int64
+	failuresDesc   *prometheus.Desc
+	failStreakDesc *prometheus.Desc
+
+	// Holds collections back after consecutive failures, nil when disabled
+	breaker     *circuitBreaker
+	breakerOpen *prometheus.Desc
+
+	// User details reused between the collections that refresh them, nil when
+	// every collection fetches them
+	details *detailsCache
+
+	// Series cap of every sub-collector in one collection, none when 0; dropped
+	// counts the series folded into "other" or left out
+	seriesLimit   int
+	seriesDropped atomic.Uint64
+	droppedDesc   *prometheus.Desc
+
+	// Caps on the users and buckets processed in one collection, none when 0
+	maxUsers      int
+	maxBuckets    int
+	truncatedDesc *prometheus.Desc
+
+	// Users skipped after RGW refused their details, nil when disabled
+	failedUsers      *failedUsers
+	usersSkipped     atomic.Uint64
+	usersSkippedDesc *prometheus.Desc
+
+	// Settled hours of the usage log, nil unless usage is collected incrementally
+	usageLog *usageLog
+
+	// Reset correction of the usage counters, nil when disabled
+	usageResets     *usageResets
+	usageResetsDesc *prometheus.Desc
+
+	// Per-second usage rates between collections, nil when disabled
+	usageRates *usageRates
+
+	// Expiry of the usage kept for deleted buckets and users, nil when disabled
+	expiry *seriesExpiry
+
+	// Per-user admin API calls run on up to concurrency goroutines
+	concurrency int
+
+	// Entries per page of metadata listings; one unpaged request when 0
+	pageSize int
+
+	// List buckets user by user instead of with one cluster-wide request
+	bucketsPerUser bool
+
+	// Bucket metrics go without the owner label, see radosgw_bucket_owner_info
+	ownerInfo bool
+
+	// Derive users from usage and bucket owners instead of listing and fetching them
+	light bool
+
+	// Deadline of one collection on top of the scraper's timeout; none when 0
+	timeout time.Duration
+
+	// Collections longer than this are logged; never when 0
+	slowWarning time.Duration
+
+	// Data of the last successful collection, served by failed ones for up to
+	// staleMaxAge or while the breaker is open
+	staleMaxAge time.Duration
+	lastGood    atomic.Pointer[snapshot]
+	staleAge    *prometheus.Desc
+
+	// Coalesces concurrent scrapes onto one backend collection; coalesced counts
+	// the scrapes that were answered by a collection started for another one
+	flight        singleflight.Group
+	coalesced     atomic.Uint64
+	coalescedDesc *prometheus.Desc
+
+	// When set, concurrent scrapes queue for their own collection instead of
+	// sharing one; holds a token while a collection runs
+	serial chan struct{}
+
+	// Background collection: when interval is set, scrapes are answered from cached,
+	// refreshed every interval or as soon as Refresh is called
+	interval time.Duration
+	refresh  chan struct{}
+	cached   atomic.Pointer[snapshot]
+	cacheAge *prometheus.Desc
+	// Keeps cached across restarts when set
+	cacheFile string
 
 	// Usage metrics
 	ops           *prometheus.Desc
@@ -33,9 +188,51 @@ type RADOSGWCollector struct {
 	bytesSent     *prometheus.Desc
 	bytesReceived *prometheus.Desc
 
+	// Usage rates
+	opsRate           *prometheus.Desc
+	successfulOpsRate *prometheus.Desc
+	bytesSentRate     *prometheus.Desc
+	bytesReceivedRate *prometheus.Desc
+
 	// Bucket metrics
-	bucketUsageBytes   *prometheus.Desc
-	bucketUsageObjects *prometheus.Desc
+	bucketUsageBytes    *prometheus.Desc
+	bucketUsageObjects  *prometheus.Desc
+	bucketUtilizedBytes *prometheus.Desc
+
+	// Incomplete multipart uploads, index entries without data, index shards,
+	// versioning, object lock, placement and quotas set on buckets themselves
+	bucketInfo              *prometheus.Desc
+	bucketOwnerInfo         *prometheus.Desc
+	bucketMultipartBytes    *prometheus.Desc
+	bucketMultipartObjects  *prometheus.Desc
+	bucketIndexOnlyBytes    *prometheus.Desc
+	bucketIndexOnlyObjects  *prometheus.Desc
+	bucketTieredBytes       *prometheus.Desc
+	bucketTieredObjects     *prometheus.Desc
+	bucketShards            *prometheus.Desc
+	bucketObjectsPerShard   *prometheus.Desc
+	bucketCreated           *prometheus.Desc
+	bucketModified          *prometheus.Desc
+	bucketVersioning        *prometheus.Desc
+	bucketVersioningPaused  *prometheus.Desc
+	bucketObjectLock        *prometheus.Desc
+	bucketResharding        *prometheus.Desc
+	bucketReshardTarget     *prometheus.Desc
+	bucketLifecycleRules    *prometheus.Desc
+	bucketCORS              *prometheus.Desc
+	bucketEncryption        *prometheus.Desc
+	bucketPublicAccess      *prometheus.Desc
+	bucketACLGrants         *prometheus.Desc
+	bucketNotifications     *prometheus.Desc
+	bucketSyncEnabled       *prometheus.Desc
+	bucketSyncBacklogShards *prometheus.Desc
+	bucketSyncOldestChange  *prometheus.Desc
+	bucketRatelimitEnabled  *prometheus.Desc
+	bucketRatelimitOps      *prometheus.Desc
+	bucketRatelimitBytes    *prometheus.Desc
+	bucketQuotaEnabled      *prometheus.Desc
+	bucketQuotaMaxSizeBytes *prometheus.Desc
+	bucketQuotaMaxObjects   *prometheus.Desc
 
 	// User metrics
 	userTotalBytes   *prometheus.Desc
@@ -51,35 +248,154 @@ type RADOSGWCollector struct {
 	userBucketQuotaMaxSizeBytes *prometheus.Desc
 	userBucketQuotaMaxObjects   *prometheus.Desc
 
+	// Buckets of users and their limit, and buckets in all and by tenant
+	userBuckets    *prometheus.Desc
+	userMaxBuckets *prometheus.Desc
+	bucketsTotal   *prometheus.Desc
+	tenantBuckets  *prometheus.Desc
+
+	// User account state, subusers and keys
+	userSuspended   *prometheus.Desc
+	userSubusers    *prometheus.Desc
+	userSubuserInfo *prometheus.Desc
+	userAccessKeys  *prometheus.Desc
+	userSwiftKeys   *prometheus.Desc
+
 	// System metrics
 	scrapeDurationSeconds *prometheus.Desc
+	scrapeEndpointCalls   *prometheus.Desc
 	up                    *prometheus.Desc
+	phaseDuration         *prometheus.Desc
+
+	// Durations of all collections, registered alongside the collector
+	durations prometheus.Histogram
+
+	// Per sub-collector outcome and duration
+	collectorSuccess  *prometheus.Desc
+	collectorDuration *prometheus.Desc
+
+	// Per-endpoint health
+	endpointUp       *prometheus.Desc
+	endpointDuration *prometheus.Desc
 }
 
 // NewRADOSGWCollector creates a new collector
-func NewRADOSGWCollector(endpoint, accessKey, secretKey, store string, insecure bool, logger *slog.Logger) *RADOSGWCollector {
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: insecure,
-			},
-		},
+func NewRADOSGWCollector(cfg Config, logger *slog.Logger) (*RADOSGWCollector, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	discovery, err := newDiscovery(cfg, logger)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("configure gateway discovery: %w", err)
+	}
+	if discovery != nil {
+		if err := discovery.refresh(ctx); err != nil {
+			logger.Warn("Initial gateway discovery failed, retrying in background", "source", discovery.source, "error", err)
+		}
+		go discovery.run(ctx)
+	}
+
+	collectors, err := enabledCollectors(cfg.Collectors)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
-	client, err := admin.New(endpoint, accessKey, secretKey, httpClient)
+	httpClient, transport := newHTTPClient(cfg, discovery)
+	client, err := admin.New(cfg.Endpoint, cfg.AccessKey, cfg.SecretKey, httpClient)
 	if err != nil {
-		logger.Error("Failed to create RGW admin client", "error", err)
-		panic(err)
+		cancel()
+		return nil, fmt.Errorf("create RGW admin client: %w", err)
+	}
+
+	store := cfg.Store
+	if cfg.StoreAutodetect {
+		store = detectStore(client, cfg, logger)
+	}
+
+	var breaker *circuitBreaker
+	if cfg.BreakerFailures > 0 {
+		breaker = newCircuitBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)
+	}
+
+	var serial chan struct{}
+	if cfg.SerializeScrapes {
+		serial = make(chan struct{}, 1)
+	}
+
+	var details *detailsCache
+	if cfg.UserDetailsEvery > 1 {
+		details = &detailsCache{every: cfg.UserDetailsEvery}
+	}
+
+	var failed *failedUsers
+	if cfg.UserFailureTTL > 0 {
+		failed = &failedUsers{ttl: cfg.UserFailureTTL, until: make(map[string]time.Time)}
 	}
 
-	bucketLabels := []string{"bucket", "owner", "category", "store"}
-	userLabels := []string{"user", "store"}
+	var settled *usageLog
+	if cfg.UsageIncremental {
+		settled = &usageLog{}
+	}
+
+	var resets *usageResets
+	if cfg.UsageResetCorrection {
+		resets = &usageResets{
+			last:   make(map[usageMetricKey]usageMetricValues),
+			offset: make(map[usageMetricKey]usageMetricValues),
+		}
+	}
+
+	var rates *usageRates
+	if cfg.UsageRates {
+		rates = &usageRates{last: make(map[usageMetricKey]usageSample)}
+	}
+
+	var expiry *seriesExpiry
+	if cfg.SeriesTTL > 0 {
+		expiry = newSeriesExpiry(cfg.SeriesTTL)
+	}
+
+	bucketLabels := []string{"bucket", "owner", "category", "store", "tenant"}
+	userLabels := []string{"user", "store", "tenant"}
+	bucketInfoLabels := []string{"bucket", "owner", "store", "tenant"}
+	if cfg.BucketOwnerInfo {
+		bucketInfoLabels = []string{"bucket", "store", "tenant"}
+	}
 
 	return &RADOSGWCollector{
-		client: client,
-		store:  store,
-		logger: logger,
+		client:         client,
+		transport:      transport,
+		store:          store,
+		logger:         logger,
+		shardIndex:     uint32(cfg.ShardIndex),
+		shardTotal:     uint32(cfg.ShardTotal),
+		collectors:     collectors,
+		concurrency:    max(cfg.Concurrency, 1),
+		pageSize:       cfg.PageSize,
+		bucketsPerUser: cfg.BucketStatsPerUser,
+		ownerInfo:      cfg.BucketOwnerInfo,
+		light:          cfg.LightMode,
+		timeout:        cfg.ScrapeTimeout,
+		slowWarning:    cfg.SlowScrapeWarning,
+		staleMaxAge:    cfg.StaleMaxAge,
+		usageLog:       settled,
+		usageResets:    resets,
+		downAfter:      max(cfg.DownAfterFailures, 1),
+		usageRates:     rates,
+		expiry:         expiry,
+		breaker:        breaker,
+		details:        details,
+		serial:         serial,
+		failedUsers:    failed,
+		seriesLimit:    cfg.SeriesLimit,
+		maxUsers:       cfg.MaxUsers,
+		maxBuckets:     cfg.MaxBuckets,
+		interval:       cfg.ScrapeInterval,
+		cacheFile:      cfg.CacheFile,
+		refresh:        make(chan struct{}, 1),
+		ctx:            ctx,
+		cancel:         cancel,
 
 		// Usage
 		ops: prometheus.NewDesc(
@@ -103,16 +419,43 @@ func NewRADOSGWCollector(endpoint, accessKey, secretKey, store string, insecure
 			bucketLabels, nil,
 		),
 
+		// Usage rates
+		opsRate: prometheus.NewDesc(
+			"radosgw_usage_ops_per_second",
+			"Operations per second since the previous collection",
+			bucketLabels, nil,
+		),
+		successfulOpsRate: prometheus.NewDesc(
+			"radosgw_usage_successful_ops_per_second",
+			"Successful operations per second since the previous collection",
+			bucketLabels, nil,
+		),
+		bytesSentRate: prometheus.NewDesc(
+			"radosgw_usage_sent_bytes_per_second",
+			"Bytes per second sent by the RADOSGW since the previous collection",
+			bucketLabels, nil,
+		),
+		bytesReceivedRate: prometheus.NewDesc(
+			"radosgw_usage_received_bytes_per_second",
+			"Bytes per second received by the RADOSGW since the previous collection",
+			bucketLabels, nil,
+		),
+
 		// Bucket
 		bucketUsageBytes: prometheus.NewDesc(
 			"radosgw_usage_bucket_bytes",
 			"Bucket used bytes",
-			bucketLabels, nil,
+			slices.Concat(bucketInfoLabels, []string{"category"}), nil,
 		),
 		bucketUsageObjects: prometheus.NewDesc(
 			"radosgw_usage_bucket_objects",
 			"Number of objects in bucket",
-			bucketLabels, nil,
+			slices.Concat(bucketInfoLabels, []string{"category"}), nil,
+		),
+		bucketUtilizedBytes: prometheus.NewDesc(
+			"radosgw_usage_bucket_utilized_bytes",
+			"Bucket used bytes after compression",
+			slices.Concat(bucketInfoLabels, []string{"category"}), nil,
 		),
 
 		// User
@@ -127,6 +470,197 @@ func NewRADOSGWCollector(endpoint, accessKey, secretKey, store string, insecure
 			userLabels, nil,
 		),
 
+		// Incomplete multipart uploads
+		bucketMultipartBytes: prometheus.NewDesc(
+			"radosgw_usage_bucket_multipart_bytes",
+			"Bytes held by incomplete multipart uploads in bucket",
+			bucketInfoLabels, nil,
+		),
+		bucketMultipartObjects: prometheus.NewDesc(
+			"radosgw_usage_bucket_multipart_objects",
+			"Number of parts of incomplete multipart uploads in bucket",
+			bucketInfoLabels, nil,
+		),
+
+		// Index entries without data
+		bucketIndexOnlyBytes: prometheus.NewDesc(
+			"radosgw_usage_bucket_index_only_bytes",
+			"Bytes accounted to index entries without data in bucket",
+			bucketInfoLabels, nil,
+		),
+		bucketIndexOnlyObjects: prometheus.NewDesc(
+			"radosgw_usage_bucket_index_only_objects",
+			"Number of index entries without data in bucket",
+			bucketInfoLabels, nil,
+		),
+
+		// Objects transitioned to cloud tiers
+		bucketTieredBytes: prometheus.NewDesc(
+			"radosgw_usage_bucket_cloudtiered_bytes",
+			"Bytes of objects of bucket transitioned to cloud tiers",
+			bucketInfoLabels, nil,
+		),
+		bucketTieredObjects: prometheus.NewDesc(
+			"radosgw_usage_bucket_cloudtiered_objects",
+			"Number of objects of bucket transitioned to cloud tiers",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket placement
+		bucketInfo: prometheus.NewDesc(
+			"radosgw_usage_bucket_info",
+			"Placement target, default storage class, zonegroup, index type, instance ID and marker of bucket, always 1",
+			slices.Concat(bucketInfoLabels, []string{"placement", "storage_class", "zonegroup", "index_type", "bucket_id", "marker"}), nil,
+		),
+
+		bucketOwnerInfo: prometheus.NewDesc(
+			"radosgw_bucket_owner_info",
+			"Owner of bucket, always 1",
+			[]string{"bucket", "owner", "store", "tenant"}, nil,
+		),
+
+		// Bucket index
+		bucketShards: prometheus.NewDesc(
+			"radosgw_usage_bucket_shards",
+			"Number of bucket index shards",
+			bucketInfoLabels, nil,
+		),
+		bucketObjectsPerShard: prometheus.NewDesc(
+			"radosgw_usage_bucket_objects_per_shard",
+			"Number of objects per bucket index shard",
+			bucketInfoLabels, nil,
+		),
+		bucketResharding: prometheus.NewDesc(
+			"radosgw_usage_bucket_resharding",
+			"Whether the bucket index is being resharded",
+			bucketInfoLabels, nil,
+		),
+		bucketReshardTarget: prometheus.NewDesc(
+			"radosgw_usage_bucket_reshard_target_shards",
+			"Number of index shards the bucket is being resharded to",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket lifecycle
+		bucketLifecycleRules: prometheus.NewDesc(
+			"radosgw_bucket_lifecycle_rules",
+			"Number of lifecycle rules configured on bucket",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket CORS
+		bucketCORS: prometheus.NewDesc(
+			"radosgw_bucket_cors_configured",
+			"Whether a CORS configuration is set on bucket",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket encryption
+		bucketEncryption: prometheus.NewDesc(
+			"radosgw_bucket_encryption_configured",
+			"Whether default server-side encryption is configured on bucket, with its algorithm",
+			slices.Concat(bucketInfoLabels, []string{"algorithm"}), nil,
+		),
+
+		// Bucket access
+		bucketPublicAccess: prometheus.NewDesc(
+			"radosgw_bucket_public_access",
+			"Whether bucket policy or ACL grants anonymous users access to bucket",
+			bucketInfoLabels, nil,
+		),
+		bucketACLGrants: prometheus.NewDesc(
+			"radosgw_bucket_acl_grants",
+			"Number of ACL grants of bucket by grantee",
+			slices.Concat(bucketInfoLabels, []string{"grantee"}), nil,
+		),
+
+		// Bucket notifications
+		bucketNotifications: prometheus.NewDesc(
+			"radosgw_bucket_notifications",
+			"Number of notification configurations of bucket",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket sync
+		bucketSyncEnabled: prometheus.NewDesc(
+			"radosgw_bucket_sync_enabled",
+			"Whether multisite data sync is enabled for bucket",
+			bucketInfoLabels, nil,
+		),
+		bucketSyncBacklogShards: prometheus.NewDesc(
+			"radosgw_bucket_sync_backlog_shards",
+			"Number of bucket index shards with changes left in the bucket index log",
+			bucketInfoLabels, nil,
+		),
+		bucketSyncOldestChange: prometheus.NewDesc(
+			"radosgw_bucket_sync_oldest_change_timestamp_seconds",
+			"Time of the oldest change left in the bucket index log in seconds since the epoch",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket rate limit
+		bucketRatelimitEnabled: prometheus.NewDesc(
+			"radosgw_bucket_ratelimit_enabled",
+			"Whether the rate limit of bucket is enabled",
+			bucketInfoLabels, nil,
+		),
+		bucketRatelimitOps: prometheus.NewDesc(
+			"radosgw_bucket_ratelimit_max_ops",
+			"Maximum operations per minute and RGW instance on bucket by op, 0 for no limit",
+			slices.Concat(bucketInfoLabels, []string{"op"}), nil,
+		),
+		bucketRatelimitBytes: prometheus.NewDesc(
+			"radosgw_bucket_ratelimit_max_bytes",
+			"Maximum bytes per minute and RGW instance on bucket by op, 0 for no limit",
+			slices.Concat(bucketInfoLabels, []string{"op"}), nil,
+		),
+
+		// Bucket times
+		bucketCreated: prometheus.NewDesc(
+			"radosgw_usage_bucket_created_timestamp_seconds",
+			"Creation time of bucket in seconds since the epoch",
+			bucketInfoLabels, nil,
+		),
+		bucketModified: prometheus.NewDesc(
+			"radosgw_usage_bucket_modified_timestamp_seconds",
+			"Last modification time of bucket in seconds since the epoch",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket versioning
+		bucketVersioning: prometheus.NewDesc(
+			"radosgw_usage_bucket_versioning_enabled",
+			"Whether versioning is enabled on bucket",
+			bucketInfoLabels, nil,
+		),
+		bucketVersioningPaused: prometheus.NewDesc(
+			"radosgw_usage_bucket_versioning_suspended",
+			"Whether versioning is suspended on bucket, which keeps the versions already written",
+			bucketInfoLabels, nil,
+		),
+		bucketObjectLock: prometheus.NewDesc(
+			"radosgw_usage_bucket_object_lock_enabled",
+			"Whether object lock is enabled on bucket",
+			bucketInfoLabels, nil,
+		),
+
+		// Bucket Quota (per-bucket)
+		bucketQuotaEnabled: prometheus.NewDesc(
+			"radosgw_usage_bucket_quota_enabled",
+			"Bucket quota enabled",
+			bucketInfoLabels, nil,
+		),
+		bucketQuotaMaxSizeBytes: prometheus.NewDesc(
+			"radosgw_usage_bucket_quota_size_bytes",
+			"Maximum allowed size in bytes for bucket",
+			bucketInfoLabels, nil,
+		),
+		bucketQuotaMaxObjects: prometheus.NewDesc(
+			"radosgw_usage_bucket_quota_size_objects",
+			"Maximum allowed number of objects in bucket",
+			bucketInfoLabels, nil,
+		),
+
 		// User Quota
 		userQuotaEnabled: prometheus.NewDesc(
 			"radosgw_usage_user_quota_enabled",
@@ -161,17 +695,222 @@ func NewRADOSGWCollector(endpoint, accessKey, secretKey, store string, insecure
 			userLabels, nil,
 		),
 
+		// User buckets
+		userBuckets: prometheus.NewDesc(
+			"radosgw_user_buckets_count",
+			"Number of buckets owned by user",
+			userLabels, nil,
+		),
+		userMaxBuckets: prometheus.NewDesc(
+			"radosgw_user_max_buckets",
+			"Maximum allowed number of buckets of user",
+			userLabels, nil,
+		),
+		bucketsTotal: prometheus.NewDesc(
+			"radosgw_buckets_count",
+			"Number of buckets",
+			[]string{"store"}, nil,
+		),
+		tenantBuckets: prometheus.NewDesc(
+			"radosgw_tenant_buckets_count",
+			"Number of buckets of tenant",
+			[]string{"tenant", "store"}, nil,
+		),
+
+		// User account state
+		userSuspended: prometheus.NewDesc(
+			"radosgw_user_suspended",
+			"Whether user is suspended",
+			userLabels, nil,
+		),
+		userSubusers: prometheus.NewDesc(
+			"radosgw_user_subusers",
+			"Number of subusers of user",
+			userLabels, nil,
+		),
+		userSubuserInfo: prometheus.NewDesc(
+			"radosgw_user_subuser_info",
+			"Subuser of user with its permissions, always 1",
+			slices.Concat(userLabels, []string{"subuser", "permissions"}), nil,
+		),
+		userAccessKeys: prometheus.NewDesc(
+			"radosgw_user_access_keys",
+			"Number of S3 access keys of user and its subusers",
+			userLabels, nil,
+		),
+		userSwiftKeys: prometheus.NewDesc(
+			"radosgw_user_swift_keys",
+			"Number of Swift keys of the subusers of user",
+			userLabels, nil,
+		),
+
 		// System
 		scrapeDurationSeconds: prometheus.NewDesc(
 			"radosgw_usage_scrape_duration_seconds",
 			"Amount of time each scrape takes",
 			nil, nil,
 		),
+		scrapeEndpointCalls: prometheus.NewDesc(
+			"radosgw_scrape_endpoint_requests",
+			"Admin API requests answered by each RGW endpoint during the last scrape",
+			[]string{"endpoint"}, nil,
+		),
 		up: prometheus.NewDesc(
 			"radosgw_up",
 			"Whether the RADOSGW exporter is able to communicate with RADOSGW.",
 			nil, nil,
 		),
+
+		collectorSuccess: prometheus.NewDesc(
+			"radosgw_collector_success",
+			"Whether the sub-collector succeeded during the last scrape",
+			[]string{"collector"}, nil,
+		),
+		durations: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "radosgw_collection_duration_seconds",
+			Help:    "Duration of collections from RGW",
+			Buckets: cfg.ScrapeDurationBuckets,
+		}),
+
+		phaseDuration: prometheus.NewDesc(
+			"radosgw_usage_scrape_phase_duration_seconds",
+			"Wall time of each phase of the last scrape: usage, users (listing, details and quotas) or buckets",
+			[]string{"phase"}, nil,
+		),
+
+		collectorDuration: prometheus.NewDesc(
+			"radosgw_collector_duration_seconds",
+			"Time the sub-collector took during the last scrape",
+			[]string{"collector"}, nil,
+		),
+
+		coalescedDesc: prometheus.NewDesc(
+			"radosgw_exporter_coalesced_scrapes_total",
+			"Scrapes answered by a collection already in flight for another scrape",
+			nil, nil,
+		),
+		cacheAge: prometheus.NewDesc(
+			"radosgw_usage_cache_age_seconds",
+			"Time since the background collection served from cache finished",
+			nil, nil,
+		),
+
+		staleAge: prometheus.NewDesc(
+			"radosgw_stale_data_age_seconds",
+			"Age of the data served in place of a failed collection, while the circuit breaker is open or on a standby replica, 0 when the last collection succeeded",
+			nil, nil,
+		),
+
+		breakerOpen: prometheus.NewDesc(
+			"radosgw_circuit_breaker_open",
+			"Whether collections are paused after consecutive RGW failures",
+			nil, nil,
+		),
+
+		truncatedDesc: prometheus.NewDesc(
+			"radosgw_collection_truncated",
+			"Whether the last collection left out users or buckets beyond RADOSGW_EXPORTER_LIMIT_MAX_USERS or RADOSGW_EXPORTER_LIMIT_MAX_BUCKETS",
+			[]string{"limit"}, nil,
+		),
+
+		droppedDesc: prometheus.NewDesc(
+			"radosgw_exporter_series_dropped_total",
+			"Series not exported individually because a collector reached RADOSGW_EXPORTER_SERIES_LIMIT",
+			nil, nil,
+		),
+
+		failuresDesc: prometheus.NewDesc(
+			"radosgw_collection_failures_total",
+			"Failed collections from RGW",
+			nil, nil,
+		),
+		failStreakDesc: prometheus.NewDesc(
+			"radosgw_collection_consecutive_failures",
+			"Failed collections from RGW since the last successful one",
+			nil, nil,
+		),
+
+		usageResetsDesc: prometheus.NewDesc(
+			"radosgw_usage_resets_total",
+			"Usage series that went down, e.g. after radosgw-admin usage trim, and were corrected",
+			nil, nil,
+		),
+
+		usersSkippedDesc: prometheus.NewDesc(
+			"radosgw_users_skipped_total",
+			"Users not queried because RGW refused their details within RADOSGW_EXPORTER_USER_FAILURE_TTL",
+			nil, nil,
+		),
+
+		leaderDesc: prometheus.NewDesc(
+			"radosgw_exporter_leader",
+			"Whether this replica is the elected leader collecting from RGW",
+			nil, nil,
+		),
+
+		// Endpoints
+		endpointUp: prometheus.NewDesc(
+			"radosgw_endpoint_up",
+			"Whether the last admin API request to the RGW endpoint succeeded",
+			[]string{"endpoint"}, nil,
+		),
+		endpointDuration: prometheus.NewDesc(
+			"radosgw_endpoint_request_duration_seconds",
+			"Latency of admin API requests to the RGW endpoint",
+			[]string{"endpoint"}, nil,
+		),
+	}, nil
+}
+
+// detectStore asks the gateway for its zone name, falling back to the configured store
+func detectStore(client *admin.API, cfg Config, logger *slog.Logger) string {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+	defer cancel()
+
+	var zone struct {
+		Name string `json:"name"`
+	}
+	if err := adminGet(ctx, client, "/config", url.Values{"type": {"zone"}}, &zone); err != nil || zone.Name == "" {
+		logger.Warn("Failed to detect zone name, using configured store", "store", cfg.Store, "error", err)
+		return cfg.Store
+	}
+	logger.Info("Detected zone name", "store", zone.Name)
+	return zone.Name
+}
+
+// Ready reports whether at least one collection has succeeded; a standby replica
+// is always ready since it is not expected to collect
+func (c *RADOSGWCollector) Ready() bool {
+	return c.ready.Load() || c.standby()
+}
+
+// begin registers an in-flight collection; it fails once Shutdown has been called
+func (c *RADOSGWCollector) begin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		return false
+	}
+	c.inflight.Add(1)
+	return true
+}
+
+// Shutdown cancels in-flight collections and waits for them to return
+func (c *RADOSGWCollector) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.cancel()
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -181,8 +920,49 @@ func (c *RADOSGWCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.successfulOps
 	ch <- c.bytesSent
 	ch <- c.bytesReceived
+	if c.usageRates != nil {
+		ch <- c.opsRate
+		ch <- c.successfulOpsRate
+		ch <- c.bytesSentRate
+		ch <- c.bytesReceivedRate
+	}
 	ch <- c.bucketUsageBytes
 	ch <- c.bucketUsageObjects
+	ch <- c.bucketUtilizedBytes
+	ch <- c.bucketInfo
+	if c.ownerInfo {
+		ch <- c.bucketOwnerInfo
+	}
+	ch <- c.bucketMultipartBytes
+	ch <- c.bucketMultipartObjects
+	ch <- c.bucketIndexOnlyBytes
+	ch <- c.bucketIndexOnlyObjects
+	ch <- c.bucketTieredBytes
+	ch <- c.bucketTieredObjects
+	ch <- c.bucketShards
+	ch <- c.bucketObjectsPerShard
+	ch <- c.bucketCreated
+	ch <- c.bucketModified
+	ch <- c.bucketVersioning
+	ch <- c.bucketVersioningPaused
+	ch <- c.bucketObjectLock
+	ch <- c.bucketResharding
+	ch <- c.bucketReshardTarget
+	ch <- c.bucketLifecycleRules
+	ch <- c.bucketCORS
+	ch <- c.bucketEncryption
+	ch <- c.bucketPublicAccess
+	ch <- c.bucketACLGrants
+	ch <- c.bucketNotifications
+	ch <- c.bucketSyncEnabled
+	ch <- c.bucketSyncBacklogShards
+	ch <- c.bucketSyncOldestChange
+	ch <- c.bucketRatelimitEnabled
+	ch <- c.bucketRatelimitOps
+	ch <- c.bucketRatelimitBytes
+	ch <- c.bucketQuotaEnabled
+	ch <- c.bucketQuotaMaxSizeBytes
+	ch <- c.bucketQuotaMaxObjects
 	ch <- c.userTotalBytes
 	ch <- c.userTotalObjects
 	ch <- c.userQuotaEnabled
@@ -191,150 +971,461 @@ func (c *RADOSGWCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.userBucketQuotaEnabled
 	ch <- c.userBucketQuotaMaxSizeBytes
 	ch <- c.userBucketQuotaMaxObjects
+	ch <- c.userBuckets
+	ch <- c.userMaxBuckets
+	ch <- c.bucketsTotal
+	ch <- c.tenantBuckets
+	ch <- c.userSuspended
+	ch <- c.userSubusers
+	ch <- c.userSubuserInfo
+	ch <- c.userAccessKeys
+	ch <- c.userSwiftKeys
 	ch <- c.scrapeDurationSeconds
+	ch <- c.scrapeEndpointCalls
 	ch <- c.up
+	ch <- c.failuresDesc
+	ch <- c.failStreakDesc
+	ch <- c.collectorSuccess
+	ch <- c.collectorDuration
+	ch <- c.phaseDuration
+	ch <- c.endpointUp
+	ch <- c.endpointDuration
+	if c.leader != nil {
+		ch <- c.leaderDesc
+	}
+	if c.interval > 0 {
+		ch <- c.cacheAge
+	} else if c.serial == nil {
+		ch <- c.coalescedDesc
+	}
+	if c.staleMaxAge > 0 || c.leader != nil || c.breaker != nil {
+		ch <- c.staleAge
+	}
+	if c.breaker != nil {
+		ch <- c.breakerOpen
+	}
+	if c.failedUsers != nil {
+		ch <- c.usersSkippedDesc
+	}
+	if c.usageResets != nil {
+		ch <- c.usageResetsDesc
+	}
+	if c.seriesLimit > 0 {
+		ch <- c.droppedDesc
+	}
+	if c.maxUsers > 0 || c.maxBuckets > 0 {
+		ch <- c.truncatedDesc
+	}
 }
 
 // Collect implements Collector
 func (c *RADOSGWCollector) Collect(ch chan<- prometheus.Metric) {
-	start := time.Now()
-	defer func() {
-		duration := time.Since(start).Seconds()
-		ch <- prometheus.MustNewConstMetric(c.scrapeDurationSeconds, prometheus.GaugeValue, duration)
-		if duration > 10.0 {
-			c.logger.Warn("Scrape took more than 10 seconds", "duration_sec", duration)
+	c.collectShared(context.Background(), ch)
+}
+
+// scrapeCollector binds a collection to the context of one scrape request
+type scrapeCollector struct {
+	*RADOSGWCollector
+	ctx context.Context
+}
+
+// Collect implements Collector
+func (s scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collectShared(s.ctx, ch)
+}
+
+// withContext returns a Collector whose collections stop when ctx is done
+func (c *RADOSGWCollector) withContext(ctx context.Context) prometheus.Collector {
+	return scrapeCollector{RADOSGWCollector: c, ctx: ctx}
+}
+
+// collectShared joins the collection already in flight, if any, or starts one with ctx,
+// and replays its metrics to ch; in background mode it replays the cached collection
+// and in serial mode it waits for the running collection to finish and runs its own
+func (c *RADOSGWCollector) collectShared(ctx context.Context, ch chan<- prometheus.Metric) {
+	if c.leader != nil {
+		ch <- prometheus.MustNewConstMetric(c.leaderDesc, prometheus.GaugeValue, boolToFloat(c.leader.IsLeader()))
+	}
+	if c.interval > 0 {
+		c.collectCached(ch)
+		return
+	}
+	if c.serial != nil {
+		select {
+		case c.serial <- struct{}{}:
+		case <-ctx.Done():
+			c.logger.Warn("Scrape gave up waiting for the running collection", "error", ctx.Err())
+			ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+			return
 		}
-	}()
+		defer func() { <-c.serial }()
+		c.collect(ctx, ch)
+		return
+	}
+	ran := false
+	v, _, _ := c.flight.Do("collect", func() (any, error) {
+		ran = true
+		return c.gather(ctx), nil
+	})
+	if !ran {
+		c.coalesced.Add(1)
+	}
+	for _, m := range v.([]prometheus.Metric) {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(c.coalescedDesc, prometheus.CounterValue, float64(c.coalesced.Load()))
+}
 
-	var up float64 = 1.0
-	defer func() {
-		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up)
+// gather runs one collection with ctx and buffers its metrics
+func (c *RADOSGWCollector) gather(ctx context.Context) []prometheus.Metric {
+	return buffered(func(ch chan<- prometheus.Metric) { c.collect(ctx, ch) })
+}
+
+// buffered returns the metrics fn sends to its channel
+func buffered(fn func(ch chan<- prometheus.Metric)) []prometheus.Metric {
+	buf := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range buf {
+			metrics = append(metrics, m)
+		}
+		done <- metrics
 	}()
+	fn(buf)
+	close(buf)
+	return <-done
+}
 
-	ctx := context.Background()
+// snapshot — metrics of one finished collection
+type snapshot struct {
+	metrics []prometheus.Metric
+	at      time.Time
+}
 
-	// === Get Usage ===
-	showEntries, showSummary := true, false
-	usage, err := c.client.GetUsage(ctx, admin.Usage{
-		ShowEntries: &showEntries,
-		ShowSummary: &showSummary,
-	})
-	if err != nil {
-		c.logger.Error("Failed to fetch usage from RADOSGW", "error", err)
-		up = 0.0
+// collectCached replays the latest background collection; nothing is exported
+// until the first one has finished
+func (c *RADOSGWCollector) collectCached(ch chan<- prometheus.Metric) {
+	snap := c.cached.Load()
+	if snap == nil {
 		return
 	}
+	for _, m := range snap.metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(c.cacheAge, prometheus.GaugeValue, time.Since(snap.at).Seconds())
+}
 
-	// Aggregate usage by unique key
-	usageAggr := make(map[usageMetricKey]*usageMetricValues)
-	for _, entry := range usage.Entries {
-		user := entry.User
-		for _, bucket := range entry.Buckets {
-			bucketName := bucket.Bucket
-			if bucketName == "" {
-				bucketName = "bucket_root"
+// Start launches the background collection loop when an interval is configured;
+// it stops on Shutdown
+func (c *RADOSGWCollector) Start() {
+	if c.interval <= 0 {
+		return
+	}
+	if c.cacheFile != "" && c.cached.Load() == nil {
+		switch snap, err := loadSnapshot(c.cacheFile); {
+		case err == nil:
+			c.cached.Store(snap)
+			c.logger.Info("Restored metrics of the previous run", "file", c.cacheFile, "age_sec", time.Since(snap.at).Seconds())
+		case !errors.Is(err, os.ErrNotExist):
+			c.logger.Warn("Failed to restore metrics of the previous run", "file", c.cacheFile, "error", err)
+		}
+	}
+	go func() {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-timer.C:
+			case <-c.refresh:
 			}
-			for _, cat := range bucket.Categories {
-				key := usageMetricKey{
-					bucket:   bucketName,
-					owner:    user,
-					category: cat.Category,
-					store:    c.store,
-				}
-				if _, exists := usageAggr[key]; !exists {
-					usageAggr[key] = &usageMetricValues{}
+			// Standby replicas keep serving their last snapshot without calling RGW
+			if c.standby() {
+				timer.Reset(c.interval)
+				continue
+			}
+			metrics := c.gather(c.ctx)
+			if c.ctx.Err() != nil {
+				return
+			}
+			snap := &snapshot{metrics: metrics, at: time.Now()}
+			c.cached.Store(snap)
+			if c.cacheFile != "" {
+				if err := saveSnapshot(c.cacheFile, snap); err != nil {
+					c.logger.Warn("Failed to save metrics cache", "file", c.cacheFile, "error", err)
 				}
-				v := usageAggr[key]
-				v.ops += float64(cat.Ops)
-				v.successfulOps += float64(cat.SuccessfulOps)
-				v.bytesSent += float64(cat.BytesSent)
-				v.bytesReceived += float64(cat.BytesReceived)
 			}
+			timer.Reset(c.interval)
+		}
+	}()
+}
+
+// WarmUp waits for up to timeout for a first successful collection, running it
+// unless the background loop does; it reports whether there is data to serve.
+// Standby replicas of leader election are always warm
+func (c *RADOSGWCollector) WarmUp(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	defer cancel()
+	if c.interval <= 0 {
+		c.gather(ctx)
+		return c.ready.Load() || c.standby()
+	}
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if c.cached.Load() != nil && c.ready.Load() || c.standby() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
 		}
 	}
+}
 
-	// Emit usage metrics
-	for key, vals := range usageAggr {
-		labels := []string{key.bucket, key.owner, key.category, key.store}
-		ch <- prometheus.MustNewConstMetric(c.ops, prometheus.CounterValue, vals.ops, labels...)
-		ch <- prometheus.MustNewConstMetric(c.successfulOps, prometheus.CounterValue, vals.successfulOps, labels...)
-		ch <- prometheus.MustNewConstMetric(c.bytesSent, prometheus.CounterValue, vals.bytesSent, labels...)
-		ch <- prometheus.MustNewConstMetric(c.bytesReceived, prometheus.CounterValue, vals.bytesReceived, labels...)
+// Refresh asks the background loop to collect right away; requests arriving while
+// a collection is pending are merged. It reports false without background collection
+func (c *RADOSGWCollector) Refresh() bool {
+	if c.interval <= 0 {
+		return false
+	}
+	select {
+	case c.refresh <- struct{}{}:
+	default:
 	}
+	return true
+}
 
-	// === Get all users ===
-	uids, err := c.client.GetUsers(ctx)
-	if err != nil {
-		c.logger.Error("Failed to list users", "error", err)
-		up = 0.0
+func (c *RADOSGWCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	if c.standby() {
+		c.collectStandby(ch)
+		return
+	}
+	if !c.begin() {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
 		return
 	}
+	defer c.inflight.Done()
 
-	// === Process users and buckets ===
-	for _, uid := range *uids {
-		user, err := c.client.GetUser(ctx, admin.User{ID: uid})
-		if err != nil {
-			c.logger.Debug("Failed to get user details", "uid", uid, "error", err)
-			continue
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		ch <- prometheus.MustNewConstMetric(c.scrapeDurationSeconds, prometheus.GaugeValue, duration)
+		c.durations.Observe(duration)
+		if c.slowWarning > 0 && duration > c.slowWarning.Seconds() {
+			c.logger.Warn("Slow scrape", "duration_sec", duration, "threshold", c.slowWarning.String())
 		}
+	}()
 
-		userLabels := []string{user.ID, c.store}
-
-		// User totals
-		if user.Stat.NumObjects != nil {
-			ch <- prometheus.MustNewConstMetric(c.userTotalObjects, prometheus.GaugeValue, float64(*user.Stat.NumObjects), userLabels...)
+	report := newScrapeReport()
+	var up float64 = 1.0
+	defer func() {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, c.reportedUp(up))
+		ch <- prometheus.MustNewConstMetric(c.failuresDesc, prometheus.CounterValue, float64(c.failures.Load()))
+		ch <- prometheus.MustNewConstMetric(c.failStreakDesc, prometheus.GaugeValue, float64(c.failStreak.Load()))
+		if up == 1.0 {
+			c.ready.Store(true)
+			if c.expiry != nil {
+				c.expiry.done()
+			}
 		}
-		if user.Stat.Size != nil {
-			ch <- prometheus.MustNewConstMetric(c.userTotalBytes, prometheus.GaugeValue, float64(*user.Stat.Size), userLabels...)
+		report.finish(up == 1.0)
+		c.lastReport.Store(report)
+		if c.breaker != nil {
+			ch <- prometheus.MustNewConstMetric(c.breakerOpen, prometheus.GaugeValue, boolToFloat(c.breaker.open()))
 		}
-
-		// User Quota
-		if user.UserQuota.Enabled != nil {
-			enabled := 0.0
-			if *user.UserQuota.Enabled {
-				enabled = 1.0
-			}
-			ch <- prometheus.MustNewConstMetric(c.userQuotaEnabled, prometheus.GaugeValue, enabled, userLabels...)
+		if c.failedUsers != nil {
+			ch <- prometheus.MustNewConstMetric(c.usersSkippedDesc, prometheus.CounterValue, float64(c.usersSkipped.Load()))
 		}
-		if user.UserQuota.MaxSizeKb != nil {
-			ch <- prometheus.MustNewConstMetric(c.userQuotaMaxSizeBytes, prometheus.GaugeValue, float64(*user.UserQuota.MaxSizeKb*1024), userLabels...)
+		if c.usageResets != nil {
+			ch <- prometheus.MustNewConstMetric(c.usageResetsDesc, prometheus.CounterValue, float64(c.usageResets.resets()))
 		}
-		if user.UserQuota.MaxObjects != nil {
-			ch <- prometheus.MustNewConstMetric(c.userQuotaMaxObjects, prometheus.GaugeValue, float64(*user.UserQuota.MaxObjects), userLabels...)
+		if c.seriesLimit > 0 {
+			ch <- prometheus.MustNewConstMetric(c.droppedDesc, prometheus.CounterValue, float64(c.seriesDropped.Load()))
 		}
-
-		// Bucket Quota (per-user)
-		if user.BucketQuota.Enabled != nil {
-			enabled := 0.0
-			if *user.BucketQuota.Enabled {
-				enabled = 1.0
-			}
-			ch <- prometheus.MustNewConstMetric(c.userBucketQuotaEnabled, prometheus.GaugeValue, enabled, userLabels...)
+		for endpoint, calls := range report.Endpoints {
+			ch <- prometheus.MustNewConstMetric(c.scrapeEndpointCalls, prometheus.GaugeValue, float64(calls), endpoint)
 		}
-		if user.BucketQuota.MaxSizeKb != nil {
-			ch <- prometheus.MustNewConstMetric(c.userBucketQuotaMaxSizeBytes, prometheus.GaugeValue, float64(*user.BucketQuota.MaxSizeKb*1024), userLabels...)
+		for endpoint, st := range c.transport.endpointStats() {
+			ch <- prometheus.MustNewConstMetric(c.endpointUp, prometheus.GaugeValue, boolToFloat(st.up), endpoint)
+			ch <- prometheus.MustNewConstSummary(c.endpointDuration, st.requests, st.seconds, nil, endpoint)
 		}
-		if user.BucketQuota.MaxObjects != nil {
-			ch <- prometheus.MustNewConstMetric(c.userBucketQuotaMaxObjects, prometheus.GaugeValue, float64(*user.BucketQuota.MaxObjects), userLabels...)
+	}()
+
+	ctx, cancel := context.WithCancel(withScrapeReport(ctx, report))
+	defer cancel()
+	defer context.AfterFunc(c.ctx, cancel)()
+	if c.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, c.timeout)
+		defer cancelTimeout()
+	}
+
+	if c.staleMaxAge <= 0 && c.leader == nil && c.breaker == nil {
+		if !c.runCollectors(ctx, report, ch, ch) {
+			up = 0.0
 		}
+		return
+	}
 
-		// Bucket stats
-		buckets, err := c.client.ListUsersBucketsWithStat(ctx, uid)
-		if err != nil {
-			c.logger.Debug("Failed to list buckets for user", "uid", uid, "error", err)
-			continue
+	// Keep the data of the last successful collection and serve it in place of the
+	// data of a failed one while it is younger than staleMaxAge, on standby and,
+	// unless staleMaxAge caps its age, while the circuit breaker is open
+	ok := true
+	data := buffered(func(data chan<- prometheus.Metric) {
+		ok = c.runCollectors(ctx, report, ch, data)
+	})
+	if ok {
+		c.lastGood.Store(&snapshot{metrics: data, at: time.Now()})
+		ch <- prometheus.MustNewConstMetric(c.staleAge, prometheus.GaugeValue, 0)
+	} else {
+		up = 0.0
+		last := c.lastGood.Load()
+		held := c.breaker != nil && c.staleMaxAge <= 0 && c.breaker.open()
+		if last != nil && (held || time.Since(last.at) <= c.staleMaxAge) {
+			c.logger.Warn("Serving metrics of the last successful collection", "age_sec", time.Since(last.at).Seconds())
+			data = last.metrics
+			ch <- prometheus.MustNewConstMetric(c.staleAge, prometheus.GaugeValue, time.Since(last.at).Seconds())
 		}
-		for _, b := range buckets {
-			bucketName := b.Bucket
-			owner := b.Owner
-			labels := []string{bucketName, owner, "bucket_total", c.store}
+	}
+	for _, m := range data {
+		ch <- m
+	}
+}
+
+// standby reports whether this replica lost leader election and must not call RGW
+func (c *RADOSGWCollector) standby() bool {
+	return c.leader != nil && !c.leader.IsLeader()
+}
+
+// collectStandby serves the data of the last successful collection of this
+// replica, from its time as leader, without calling RGW; nothing is exported when
+// it has never collected
+func (c *RADOSGWCollector) collectStandby(ch chan<- prometheus.Metric) {
+	last := c.lastGood.Load()
+	if last == nil {
+		return
+	}
+	for _, m := range last.metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(c.staleAge, prometheus.GaugeValue, time.Since(last.at).Seconds())
+}
+
+// reportedUp counts the outcome of a collection and returns the radosgw_up to
+// export for it: failures only bring it down once downAfter of them are in a row
+func (c *RADOSGWCollector) reportedUp(up float64) float64 {
+	if up == 1.0 {
+		c.failStreak.Store(0)
+		return up
+	}
+	// Collections stopped by Shutdown say nothing about RGW
+	if c.ctx.Err() != nil {
+		return up
+	}
+	c.failures.Add(1)
+	if c.failStreak.Add(1) < int64(c.downAfter) {
+		return 1.0
+	}
+	return up
+}
 
-			if b.Usage.RgwMain.NumObjects != nil {
-				ch <- prometheus.MustNewConstMetric(c.bucketUsageObjects, prometheus.GaugeValue, float64(*b.Usage.RgwMain.NumObjects), labels...)
+// runCollectors runs the enabled sub-collectors, sending their outcome to ch and
+// their metrics to data; it reports whether all of them succeeded
+func (c *RADOSGWCollector) runCollectors(ctx context.Context, report *scrapeReport, ch, data chan<- prometheus.Metric) (ok bool) {
+	if c.breaker != nil {
+		if !c.breaker.allow() {
+			report.addError(errors.New("circuit breaker open, RGW not called"))
+			return false
+		}
+		defer func() {
+			// Collections stopped by Shutdown say nothing about RGW
+			if c.ctx.Err() != nil {
+				c.breaker.release()
+				return
 			}
-			if b.Usage.RgwMain.SizeActual != nil {
-				ch <- prometheus.MustNewConstMetric(c.bucketUsageBytes, prometheus.GaugeValue, float64(*b.Usage.RgwMain.SizeActual), labels...)
+			switch opened, closed := c.breaker.record(ok); {
+			case opened:
+				c.logger.Warn("Circuit breaker opened, pausing RGW collections", "failures", c.breaker.threshold, "cooldown", c.breaker.cooldown.String())
+			case closed:
+				c.logger.Info("Circuit breaker closed, RGW collection succeeded")
 			}
+		}()
+	}
+
+	// Sub-collectors run in parallel and share the admin API results of the state
+	state := &scrapeState{report: report, data: data, usageDone: make(chan struct{})}
+	state.reuseDetails = c.details != nil && c.details.begin()
+	if c.seriesLimit > 0 {
+		state.guards = make(map[string]*seriesGuard, len(c.collectors))
+		for _, sc := range c.collectors {
+			state.guards[sc.name] = newSeriesGuard(c.seriesLimit, &c.seriesDropped)
 		}
 	}
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+		phases phaseSpans
+	)
+	usage := false
+	for _, sc := range c.collectors {
+		usage = usage || sc.name == "usage"
+		wg.Go(func() {
+			if sc.name == "usage" {
+				defer close(state.usageDone)
+			}
+			phaseStart := time.Now()
+			// Once the deadline has passed the remaining sub-collectors are not started
+			err := ctx.Err()
+			if err == nil {
+				err = sc.update(c, ctx, state, data)
+			} else {
+				err = fmt.Errorf("collection cancelled: %w", err)
+			}
+			report.phase(sc.name, phaseStart)
+			phases.add(sc.phase, phaseStart, time.Now())
+			ch <- prometheus.MustNewConstMetric(c.collectorDuration, prometheus.GaugeValue, time.Since(phaseStart).Seconds(), sc.name)
+			ch <- prometheus.MustNewConstMetric(c.collectorSuccess, prometheus.GaugeValue, boolToFloat(err == nil), sc.name)
+			if err != nil {
+				c.logger.Error("Collector failed", "collector", sc.name, "error", err)
+				report.addError(fmt.Errorf("%s: %w", sc.name, err))
+				failed.Store(true)
+			}
+		})
+	}
+	if !usage {
+		close(state.usageDone)
+	}
+	wg.Wait()
+	for phase, span := range phases.spans {
+		ch <- prometheus.MustNewConstMetric(c.phaseDuration, prometheus.GaugeValue, span[1].Sub(span[0]).Seconds(), phase)
+	}
+	if c.maxUsers > 0 {
+		ch <- prometheus.MustNewConstMetric(c.truncatedDesc, prometheus.GaugeValue, boolToFloat(state.truncatedUsers.Load()), "users")
+	}
+	if c.maxBuckets > 0 {
+		ch <- prometheus.MustNewConstMetric(c.truncatedDesc, prometheus.GaugeValue, boolToFloat(state.truncatedBuckets.Load()), "buckets")
+	}
+	return !failed.Load()
+}
+
+// ownsUser reports whether uid belongs to this replica's shard
+func (c *RADOSGWCollector) ownsUser(uid string) bool {
+	if c.shardTotal <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(uid))
+	return h.Sum32()%c.shardTotal == c.shardIndex
+}
+
+// boolToFloat converts a flag to a 0/1 gauge value
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
 }
@@ -0,0 +1,1868 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ceph/go-ceph/rgw/admin"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// subCollector — independently switchable part of a collection; phase groups the
+// sub-collectors reading the same kind of admin API data, off ones are disabled
+// unless turned on
+type subCollector struct {
+	name   string
+	phase  string
+	off    bool
+	update func(c *RADOSGWCollector, ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error
+}
+
+// subCollectors — all sub-collectors, run in parallel; each one can be turned off
+// with RADOSGW_EXPORTER_COLLECTOR_<NAME>=false, or on with =true
+var subCollectors = []subCollector{
+	{name: "usage", phase: "usage", update: (*RADOSGWCollector).collectUsage},
+	{name: "users", phase: "users", update: (*RADOSGWCollector).collectUsers},
+	{name: "user_quotas", phase: "users", update: (*RADOSGWCollector).collectUserQuotas},
+	{name: "user_info", phase: "users", update: (*RADOSGWCollector).collectUserInfo},
+	{name: "bucket_stats", phase: "buckets", update: (*RADOSGWCollector).collectBucketStats},
+	{name: "bucket_reshard", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketReshard},
+	{name: "bucket_lifecycle", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketLifecycle},
+	{name: "bucket_cors", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketCORS},
+	{name: "bucket_encryption", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketEncryption},
+	{name: "bucket_access", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketAccess},
+	{name: "bucket_notifications", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketNotifications},
+	{name: "bucket_sync", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketSync},
+	{name: "bucket_sync_backlog", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketSyncBacklog},
+	{name: "bucket_ratelimit", phase: "buckets", off: true, update: (*RADOSGWCollector).collectBucketRatelimit},
+}
+
+// instanceCollectors — sub-collectors working on the instances of the listed
+// buckets; the metadata of every instance is fetched once for all of them
+var instanceCollectors = []string{"bucket_reshard", "bucket_lifecycle", "bucket_cors", "bucket_encryption", "bucket_access", "bucket_notifications", "bucket_sync", "bucket_sync_backlog", "bucket_ratelimit"}
+
+// enabledCollectors resolves names to sub-collectors, keeping the order above
+func enabledCollectors(names []string) ([]subCollector, error) {
+	for _, name := range names {
+		known := false
+		for _, sc := range subCollectors {
+			known = known || sc.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	var out []subCollector
+	for _, sc := range subCollectors {
+		for _, name := range names {
+			if sc.name == name {
+				out = append(out, sc)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// scrapeState — admin API results shared by the sub-collectors of one collection,
+// so that the user list, user details and bucket stats are fetched at most once
+type scrapeState struct {
+	report *scrapeReport
+	// Channel of the collected metrics, shared by all sub-collectors
+	data chan<- prometheus.Metric
+
+	usersOnce sync.Once
+	uids      []string
+	usersErr  error
+
+	bucketsOnce sync.Once
+	ownerTotals map[string]*bucketTotals
+	// Instances of the listed buckets, kept for the instance collectors only
+	bucketRefs []bucketRef
+	bucketsErr error
+
+	instancesOnce sync.Once
+	instances     []instanceInfo
+	instancesErr  error
+
+	mu          sync.Mutex
+	details     map[string]*userDetails
+	userBuckets map[string]*userBuckets
+	// User details of earlier collections may be served, see detailsCache
+	reuseDetails bool
+	// Series limits by sub-collector, nil without a limit
+	guards map[string]*seriesGuard
+
+	// Users and buckets left out once the per-collection caps were reached
+	truncatedUsers   atomic.Bool
+	truncatedBuckets atomic.Bool
+	bucketsTaken     atomic.Int64
+	// Owners seen in the usage log, the user list of light mode; complete once
+	// usageDone is closed
+	owners    map[string]struct{}
+	usageDone chan struct{}
+}
+
+// users returns the uids of this replica's shard
+func (s *scrapeState) users(ctx context.Context, c *RADOSGWCollector) ([]string, error) {
+	s.usersOnce.Do(func() {
+		phaseStart := time.Now()
+		defer s.report.phase("list_users", phaseStart)
+		var uids []string
+		var err error
+		if c.light {
+			uids, err = s.derivedUsers(ctx, c)
+		} else if c.pageSize > 0 {
+			uids, err = listMetadata(ctx, c.client, "user", c.pageSize)
+		} else {
+			var all *[]string
+			if all, err = c.client.GetUsers(ctx); err == nil {
+				uids = *all
+			}
+		}
+		if err != nil {
+			s.usersErr = fmt.Errorf("list users: %w", err)
+			return
+		}
+		for _, uid := range uids {
+			if !c.ownsUser(uid) {
+				continue
+			}
+			if c.expiry != nil {
+				c.expiry.observeUser(uid)
+			}
+			if c.maxUsers > 0 && len(s.uids) == c.maxUsers {
+				s.truncatedUsers.Store(true)
+				break
+			}
+			s.uids = append(s.uids, uid)
+		}
+		s.report.count(&s.report.Users, len(s.uids))
+	})
+	return s.uids, s.usersErr
+}
+
+// errBucketLimit stops reading the bucket listing once the cap has been reached
+var errBucketLimit = errors.New("bucket limit reached")
+
+// takeBucket counts one more bucket of the collection; it reports false, marking
+// the collection truncated, once the cap has been reached
+func (s *scrapeState) takeBucket(c *RADOSGWCollector) bool {
+	if c.maxBuckets > 0 && s.bucketsTaken.Add(1) > int64(c.maxBuckets) {
+		s.truncatedBuckets.Store(true)
+		return false
+	}
+	return true
+}
+
+// guard returns the series guard of the named sub-collector, nil without a limit
+func (s *scrapeState) guard(name string) *seriesGuard {
+	return s.guards[name]
+}
+
+// derivedUsers builds the user list of light mode from the owners of the usage log
+// and of the buckets instead of listing users; users outside both are not seen
+func (s *scrapeState) derivedUsers(ctx context.Context, c *RADOSGWCollector) ([]string, error) {
+	owners, err := s.buckets(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-s.usageDone:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.mu.Lock()
+	seen := make(map[string]struct{}, len(s.owners)+len(owners))
+	for uid := range s.owners {
+		seen[uid] = struct{}{}
+	}
+	s.mu.Unlock()
+	for uid := range owners {
+		seen[uid] = struct{}{}
+	}
+	uids := make([]string, 0, len(seen))
+	for uid := range seen {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids, nil
+}
+
+// bucketTotals — size and object count summed over the buckets of one owner, or
+// over those folded into the other series, which also sum the size after compression
+type bucketTotals struct {
+	bytes, utilized, objects uint64
+	buckets                  int
+}
+
+// bucketStats — a bucket of the stats listings. go-ceph decodes only the rgw.main
+// and rgw.multimeta usage sections; Usage keeps rgw.none and rgw.cloudtiered as
+// well. ObjectLockEnabled stays nil before Quincy, which does not dump it. The
+// embedded Bucket shadows the bucket name, which is Bucket.Bucket
+type bucketStats struct {
+	admin.Bucket
+	ObjectLockEnabled *bool `json:"object_lock_enabled"`
+	Usage             struct {
+		RgwMain        admin.RgwUsage `json:"rgw.main"`
+		RgwMultimeta   admin.RgwUsage `json:"rgw.multimeta"`
+		RgwNone        admin.RgwUsage `json:"rgw.none"`
+		RgwCloudtiered admin.RgwUsage `json:"rgw.cloudtiered"`
+	} `json:"usage"`
+}
+
+// versioning reports whether versioning of the bucket is enabled or suspended;
+// Reef dumps two flags, other releases the state as a string
+func (b bucketStats) versioning() (enabled, suspended, ok bool) {
+	if b.Versioning != nil {
+		switch *b.Versioning {
+		case "enabled":
+			return true, false, true
+		case "suspended":
+			return false, true, true
+		case "off":
+			return false, false, true
+		}
+		return false, false, false
+	}
+	if b.Versioned == nil || b.VersioningEnabled == nil {
+		return false, false, false
+	}
+	return *b.VersioningEnabled, *b.Versioned && !*b.VersioningEnabled, true
+}
+
+// bucketRef — a bucket instance as named in the metadata API
+type bucketRef struct {
+	bucket, tenant, id, owner string
+	shards                    uint64
+}
+
+// bucketLabelValues returns the bucket, owner, store and tenant label values of a
+// bucket metric followed by extra ones, leaving the owner out when
+// radosgw_bucket_owner_info exports it. The tenant is the bucket's own, which
+// may differ from its owner's
+func (c *RADOSGWCollector) bucketLabelValues(bucket, tenant, owner string, extra ...string) []string {
+	labels := []string{bucket}
+	if !c.ownerInfo {
+		labels = append(labels, owner)
+	}
+	return append(append(labels, c.store, tenant), extra...)
+}
+
+// userLabelValues returns the user, store and tenant label values of a user
+// metric; the user keeps its full ID, as owner does on bucket metrics
+func (c *RADOSGWCollector) userLabelValues(uid string) []string {
+	return []string{uid, c.store, tenantOf(uid)}
+}
+
+// tenantOf returns the tenant of a user ID such as "tenant$user", empty for users
+// outside tenants; the other series are labelled other
+func tenantOf(uid string) string {
+	if uid == otherLabel {
+		return otherLabel
+	}
+	tenant, _, ok := strings.Cut(uid, "$")
+	if !ok {
+		return ""
+	}
+	return tenant
+}
+
+// shardCount returns the index shard count of bucket stats, 0 when not reported
+func shardCount(n *uint64) uint64 {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+// key returns the bucket name the admin API expects, prefixed with its tenant
+func (b bucketRef) key() string {
+	if b.tenant != "" {
+		return b.tenant + "/" + b.bucket
+	}
+	return b.bucket
+}
+
+// buckets streams the cluster-wide bucket stats listing on first use. The stats
+// of every bucket of the shard are exported as they are read when the
+// bucket_stats collector is enabled and does not list buckets user by user, along
+// with the bucket count of every owner; only the totals per owner are kept
+func (s *scrapeState) buckets(ctx context.Context, c *RADOSGWCollector) (map[string]*bucketTotals, error) {
+	s.bucketsOnce.Do(func() {
+		// Buckets listed user by user are exported and counted against the cap there
+		perUser := c.enabled("bucket_stats") && c.bucketsPerUser && !c.light
+		export := c.enabled("bucket_stats") && !perUser
+		counted := !perUser
+		refs := c.readsInstances()
+		guard := s.guard("bucket_stats")
+		owners := make(map[string]*bucketTotals)
+		tenants := make(map[string]int)
+		count := 0
+		err := adminStream(ctx, c.client, "/bucket", url.Values{"stats": {"true"}}, func(dec *json.Decoder) error {
+			return decodeArray(dec, func() error {
+				var b bucketStats
+				if err := dec.Decode(&b); err != nil {
+					return err
+				}
+				if !c.ownsUser(b.Owner) {
+					return nil
+				}
+				if c.expiry != nil {
+					c.expiry.observeBucket(b.Owner, b.Bucket.Bucket)
+				}
+				if counted && !s.takeBucket(c) {
+					return errBucketLimit
+				}
+				count++
+				if export {
+					c.emitBucket(b, guard, s.data)
+				}
+				if refs {
+					s.bucketRefs = append(s.bucketRefs, bucketRef{bucket: b.Bucket.Bucket, tenant: b.Tenant, id: b.ID, owner: b.Owner, shards: shardCount(b.NumShards)})
+				}
+				t, ok := owners[b.Owner]
+				if !ok {
+					t = &bucketTotals{}
+					owners[b.Owner] = t
+				}
+				t.buckets++
+				tenants[b.Tenant]++
+				if b.Usage.RgwMain.Size != nil {
+					t.bytes += *b.Usage.RgwMain.Size
+				}
+				if b.Usage.RgwMain.NumObjects != nil {
+					t.objects += *b.Usage.RgwMain.NumObjects
+				}
+				return nil
+			})
+		})
+		if counted {
+			s.report.count(&s.report.Buckets, count)
+		}
+		if export {
+			c.emitOtherBuckets(guard, s.data)
+		}
+		// Counts of a listing cut short by the bucket cap would be too low
+		if export && err == nil {
+			for owner, t := range owners {
+				c.emitUserBuckets(owner, t.buckets, guard, s.data)
+			}
+			c.emitBucketCounts(tenants, s.data)
+		}
+		if errors.Is(err, errBucketLimit) {
+			err = nil
+		}
+		if err != nil {
+			s.bucketsErr = fmt.Errorf("list buckets: %w", err)
+			return
+		}
+		s.ownerTotals = owners
+	})
+	return s.ownerTotals, s.bucketsErr
+}
+
+// userDetails — details of one user, fetched once by whichever sub-collector
+// asks first
+type userDetails struct {
+	once sync.Once
+	user admin.User
+	err  error
+	// Whether the details were kept from an earlier collection
+	kept bool
+}
+
+// user returns the details of uid, fetching them on first use
+func (s *scrapeState) user(ctx context.Context, c *RADOSGWCollector, uid string) (admin.User, error) {
+	s.mu.Lock()
+	if s.details == nil {
+		s.details = make(map[string]*userDetails)
+	}
+	d, ok := s.details[uid]
+	if !ok {
+		d = &userDetails{}
+		s.details[uid] = d
+	}
+	s.mu.Unlock()
+	d.once.Do(func() {
+		if c.failedUsers != nil && c.failedUsers.skip(uid) {
+			c.usersSkipped.Add(1)
+			d.err = errUserSkipped
+			return
+		}
+		if s.reuseDetails {
+			if user, ok := c.details.get(uid); ok {
+				d.user, d.kept = user, true
+				return
+			}
+		}
+		d.user, d.err = c.client.GetUser(ctx, admin.User{ID: uid})
+		if d.err == nil && c.details != nil {
+			c.details.put(uid, d.user)
+		}
+		// Only refusals by RGW are remembered, not transport failures or deadlines
+		var urlErr *url.Error
+		if d.err != nil && c.failedUsers != nil && ctx.Err() == nil && !errors.As(d.err, &urlErr) {
+			c.failedUsers.add(uid)
+		}
+	})
+	return d.user, d.err
+}
+
+// keptUser reports whether the details user returned for uid were kept from an
+// earlier collection
+func (s *scrapeState) keptUser(uid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.details[uid]
+	return ok && d.kept
+}
+
+// userBuckets — the buckets of one user, listed once by whichever sub-collector
+// asks first
+type userBuckets struct {
+	once    sync.Once
+	buckets []bucketStats
+	err     error
+}
+
+// bucketsOf returns the buckets of uid with their stats, listing them on first use
+func (s *scrapeState) bucketsOf(ctx context.Context, c *RADOSGWCollector, uid string) ([]bucketStats, error) {
+	s.mu.Lock()
+	if s.userBuckets == nil {
+		s.userBuckets = make(map[string]*userBuckets)
+	}
+	b, ok := s.userBuckets[uid]
+	if !ok {
+		b = &userBuckets{}
+		s.userBuckets[uid] = b
+	}
+	s.mu.Unlock()
+	b.once.Do(func() {
+		b.err = adminGet(ctx, c.client, "/bucket", url.Values{"uid": {uid}, "stats": {"true"}}, &b.buckets)
+	})
+	return b.buckets, b.err
+}
+
+// detailsCache keeps user details across collections: they are fetched again every
+// `every` collections, in between only users missing from the cache are fetched.
+// The sizes of users are left out, as they change between collections
+type detailsCache struct {
+	every int
+
+	mu    sync.Mutex
+	round int
+	users map[string]admin.User
+}
+
+// begin counts a collection; it reports whether the cached details may be served
+// and drops them when they are due for a refresh
+func (d *detailsCache) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reuse := d.round%d.every != 0
+	d.round++
+	if !reuse {
+		d.users = make(map[string]admin.User)
+	}
+	return reuse
+}
+
+// get returns the cached details of uid
+func (d *detailsCache) get(uid string) (admin.User, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user, ok := d.users[uid]
+	return user, ok
+}
+
+// put caches the details of uid, without its sizes
+func (d *detailsCache) put(uid string, user admin.User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user.Stat = admin.UserStat{}
+	d.users[uid] = user
+}
+
+// errUserSkipped — the user's details were refused recently and are not asked for again
+var errUserSkipped = errors.New("user skipped after a recent failure")
+
+// failedUsers remembers the users whose details RGW refused, deleted mid-scrape
+// or of a broken tenant, so that they are skipped until ttl has passed
+type failedUsers struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// skip reports whether uid failed less than ttl ago
+func (f *failedUsers) skip(uid string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	until, ok := f.until[uid]
+	if ok && time.Now().After(until) {
+		delete(f.until, uid)
+		return false
+	}
+	return ok
+}
+
+// add records a failure of uid
+func (f *failedUsers) add(uid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.until[uid] = time.Now().Add(f.ttl)
+}
+
+// phaseSpans records the wall time from the first start to the last end of the
+// sub-collectors of every phase
+type phaseSpans struct {
+	mu    sync.Mutex
+	spans map[string][2]time.Time
+}
+
+// add extends the span of phase to cover start..end
+func (p *phaseSpans) add(phase string, start, end time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spans == nil {
+		p.spans = make(map[string][2]time.Time)
+	}
+	span, ok := p.spans[phase]
+	if !ok || start.Before(span[0]) {
+		span[0] = start
+	}
+	if end.After(span[1]) {
+		span[1] = end
+	}
+	p.spans[phase] = span
+}
+
+// enabled reports whether the named sub-collector runs in this collector
+func (c *RADOSGWCollector) enabled(name string) bool {
+	for _, sc := range c.collectors {
+		if sc.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readsInstances reports whether any instance collector is enabled
+func (c *RADOSGWCollector) readsInstances() bool {
+	for _, name := range instanceCollectors {
+		if c.enabled(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// eachUser calls fn for every user of the shard on up to c.concurrency goroutines,
+// stopping when ctx is done; fn must be safe for concurrent use
+func (s *scrapeState) eachUser(ctx context.Context, c *RADOSGWCollector, fn func(uid string)) error {
+	uids, err := s.users(ctx, c)
+	if err != nil {
+		return err
+	}
+	return forEach(ctx, c.concurrency, uids, fn)
+}
+
+// forEach calls fn for every item on up to limit goroutines
+func forEach[T any](ctx context.Context, limit int, items []T, fn func(T)) error {
+	var g errgroup.Group
+	g.SetLimit(limit)
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			// Items still queued when the deadline passes are skipped
+			if ctx.Err() == nil {
+				fn(item)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+	if ctx.Err() != nil {
+		return fmt.Errorf("collection cancelled: %w", ctx.Err())
+	}
+	return nil
+}
+
+// usageTimeLayout — format of the usage API start and end parameters, in UTC
+const usageTimeLayout = "2006-01-02 15:04:05"
+
+// usageLog accumulates the usage of settled hours so that incremental collections
+// only fetch the log from marker on; RGW keeps usage in hourly records
+type usageLog struct {
+	mu     sync.Mutex
+	marker time.Time
+	// Totals of settled hours by owner
+	totals map[string]usageAggregate
+}
+
+// usageResets notices usage series going down, as after `radosgw-admin usage
+// trim`, and keeps them monotonic by adding what they had counted before
+type usageResets struct {
+	mu     sync.Mutex
+	last   map[usageMetricKey]usageMetricValues
+	offset map[usageMetricKey]usageMetricValues
+	count  uint64
+}
+
+// correct returns the reset-corrected values of key given the ones RGW reported
+func (r *usageResets) correct(key usageMetricKey, v usageMetricValues) usageMetricValues {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.last[key]; ok && (v.ops < last.ops || v.successfulOps < last.successfulOps ||
+		v.bytesSent < last.bytesSent || v.bytesReceived < last.bytesReceived) {
+		r.offset[key] = r.offset[key].plus(last)
+		r.count++
+	}
+	r.last[key] = v
+	return v.plus(r.offset[key])
+}
+
+// forget drops the corrections of the series expired reports true for
+func (r *usageResets) forget(expired func(usageMetricKey) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.last {
+		if expired(key) {
+			delete(r.last, key)
+			delete(r.offset, key)
+		}
+	}
+}
+
+// resets returns the number of resets seen so far
+func (r *usageResets) resets() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// usageRates turns the usage counters into per-second rates between consecutive
+// collections, for consumers that cannot compute rates themselves
+type usageRates struct {
+	mu   sync.Mutex
+	last map[usageMetricKey]usageSample
+}
+
+// forget drops the rates of the series expired reports true for
+func (r *usageRates) forget(expired func(usageMetricKey) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.last {
+		if expired(key) {
+			delete(r.last, key)
+		}
+	}
+}
+
+// usageSample — values of a usage series and when they were seen
+type usageSample struct {
+	at     time.Time
+	values usageMetricValues
+}
+
+// rate records v for key and returns its rate since key was last seen; ok is
+// false the first time. A counter that went down is taken to have restarted from 0
+func (r *usageRates) rate(key usageMetricKey, v usageMetricValues, now time.Time) (rates usageMetricValues, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.last[key]
+	r.last[key] = usageSample{at: now, values: v}
+	elapsed := now.Sub(last.at).Seconds()
+	if !ok || elapsed <= 0 {
+		return usageMetricValues{}, false
+	}
+	perSecond := func(cur, prev float64) float64 {
+		if cur < prev {
+			prev = 0
+		}
+		return (cur - prev) / elapsed
+	}
+	return usageMetricValues{
+		ops:           perSecond(v.ops, last.values.ops),
+		successfulOps: perSecond(v.successfulOps, last.values.successfulOps),
+		bytesSent:     perSecond(v.bytesSent, last.values.bytesSent),
+		bytesReceived: perSecond(v.bytesReceived, last.values.bytesReceived),
+	}, true
+}
+
+// usageEntry — the usage of one user as listed by the usage API; RGW sorts the
+// log by user, so every user comes in a single entry
+type usageEntry struct {
+	User    string `json:"user"`
+	Buckets []struct {
+		Bucket     string `json:"bucket"`
+		Epoch      uint64 `json:"epoch"`
+		Categories []struct {
+			Category      string `json:"category"`
+			BytesSent     uint64 `json:"bytes_sent"`
+			BytesReceived uint64 `json:"bytes_received"`
+			Ops           uint64 `json:"ops"`
+			SuccessfulOps uint64 `json:"successful_ops"`
+		} `json:"categories"`
+	} `json:"buckets"`
+}
+
+// collectUsage exports the usage log aggregated per bucket, owner and category.
+// The response is decoded entry by entry, so only the aggregates are held and
+// never the whole body
+func (c *RADOSGWCollector) collectUsage(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	args := url.Values{"show-entries": {"true"}, "show-summary": {"false"}}
+	settled := c.usageLog
+	var since, marker time.Time
+	if settled != nil {
+		settled.mu.Lock()
+		defer settled.mu.Unlock()
+		since = settled.marker
+		if !since.IsZero() {
+			args.Set("start", since.Format(usageTimeLayout))
+		}
+		// The previous hour is fetched once more, the gateways may still be flushing it
+		marker = time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	}
+	if c.expiry != nil {
+		c.expireUsage()
+	}
+
+	// Settled hours join the totals only once the whole log has been read, so a
+	// failed collection does not count them twice
+	guard := s.guard("usage")
+	pending := make(map[string]usageAggregate)
+	// RGW opens a new entry whenever the owner changes between its batches of
+	// records, so a user may be listed several times; its entries are summed and
+	// exported once the whole log has been read, in the order users first appear
+	listed := make(map[string]usageAggregate)
+	var order []string
+	entries := 0
+	err := adminStream(ctx, c.client, "/usage", args, func(dec *json.Decoder) error {
+		return decodeObject(dec, func(key string) error {
+			if key != "entries" {
+				var skip json.RawMessage
+				return dec.Decode(&skip)
+			}
+			return decodeArray(dec, func() error {
+				var entry usageEntry
+				if err := dec.Decode(&entry); err != nil {
+					return err
+				}
+				entries++
+				user := entry.User
+				if !c.ownsUser(user) {
+					return nil
+				}
+				usageAggr, ok := listed[user]
+				if !ok {
+					usageAggr = make(usageAggregate)
+					listed[user] = usageAggr
+					order = append(order, user)
+					if c.expiry != nil {
+						c.expiry.observeUser(user)
+					}
+					if c.light {
+						s.mu.Lock()
+						if s.owners == nil {
+							s.owners = make(map[string]struct{})
+						}
+						s.owners[user] = struct{}{}
+						s.mu.Unlock()
+					}
+				}
+				for _, bucket := range entry.Buckets {
+					target := usageAggr
+					if settled != nil {
+						epoch := time.Unix(int64(bucket.Epoch), 0)
+						if !since.IsZero() && epoch.Before(since) {
+							continue
+						}
+						if epoch.Before(marker) {
+							if pending[user] == nil {
+								pending[user] = make(usageAggregate)
+							}
+							target = pending[user]
+						}
+					}
+					bucketName := bucket.Bucket
+					if bucketName == "" {
+						bucketName = "bucket_root"
+					}
+					if c.expiry != nil && bucketName != "bucket_root" {
+						c.expiry.observeBucket(user, bucketName)
+					}
+					for _, cat := range bucket.Categories {
+						key := usageMetricKey{
+							bucket:   bucketName,
+							owner:    user,
+							category: cat.Category,
+							store:    c.store,
+						}
+						target.add(key, usageMetricValues{
+							ops:           float64(cat.Ops),
+							successfulOps: float64(cat.SuccessfulOps),
+							bytesSent:     float64(cat.BytesSent),
+							bytesReceived: float64(cat.BytesReceived),
+						})
+					}
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("get usage: %w", err)
+	}
+	s.report.count(&s.report.UsageEntries, entries)
+
+	for _, user := range order {
+		usageAggr := listed[user]
+		if settled != nil {
+			usageAggr.merge(settled.totals[user])
+			usageAggr.merge(pending[user])
+		}
+		c.emitUsage(usageAggr, guard, ch)
+	}
+	if settled != nil {
+		// Users without recent activity are exported from the settled totals alone
+		for user, totals := range settled.totals {
+			if _, ok := listed[user]; !ok {
+				c.emitUsage(totals, guard, ch)
+			}
+		}
+		if settled.totals == nil {
+			settled.totals = make(map[string]usageAggregate)
+		}
+		for user, aggr := range pending {
+			if settled.totals[user] == nil {
+				settled.totals[user] = make(usageAggregate)
+			}
+			settled.totals[user].merge(aggr)
+		}
+		settled.marker = marker
+	}
+	if _, other, ok := guard.other(); ok {
+		c.emitUsage(other, nil, ch)
+	}
+	return nil
+}
+
+// expireUsage forgets the usage kept for buckets and users RGW no longer reports;
+// the settled totals are locked by the caller
+func (c *RADOSGWCollector) expireUsage() {
+	if c.usageLog != nil {
+		expired := 0
+		for owner, aggr := range c.usageLog.totals {
+			for key := range aggr {
+				if c.expiry.expired(key) {
+					delete(aggr, key)
+					expired++
+				}
+			}
+			if len(aggr) == 0 {
+				delete(c.usageLog.totals, owner)
+			}
+		}
+		if expired > 0 {
+			c.logger.Debug("Expired settled usage", "series", expired)
+		}
+	}
+	if c.usageResets != nil {
+		c.usageResets.forget(c.expiry.expired)
+	}
+	if c.usageRates != nil {
+		c.usageRates.forget(c.expiry.expired)
+	}
+}
+
+// emitUsage exports aggregated usage; keys beyond the series limit of guard are
+// folded into its other series
+func (c *RADOSGWCollector) emitUsage(usageAggr usageAggregate, guard *seriesGuard, ch chan<- prometheus.Metric) {
+	keys := make([]usageMetricKey, 0, len(usageAggr))
+	for key := range usageAggr {
+		keys = append(keys, key)
+	}
+	// A stable order keeps the same keys in the other series from one scrape to the next
+	if guard != nil {
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].bucket != keys[j].bucket {
+				return keys[i].bucket < keys[j].bucket
+			}
+			return keys[i].category < keys[j].category
+		})
+	}
+	for _, key := range keys {
+		vals := usageAggr[key]
+		if !guard.allow(4) {
+			guard.foldUsage(key, *vals)
+			continue
+		}
+		if c.usageResets != nil {
+			corrected := c.usageResets.correct(key, *vals)
+			vals = &corrected
+		}
+		// The usage log names buckets without their tenant, the owner's stands in
+		labels := []string{key.bucket, key.owner, key.category, key.store, tenantOf(key.owner)}
+		ch <- prometheus.MustNewConstMetric(c.ops, prometheus.CounterValue, vals.ops, labels...)
+		ch <- prometheus.MustNewConstMetric(c.successfulOps, prometheus.CounterValue, vals.successfulOps, labels...)
+		ch <- prometheus.MustNewConstMetric(c.bytesSent, prometheus.CounterValue, vals.bytesSent, labels...)
+		ch <- prometheus.MustNewConstMetric(c.bytesReceived, prometheus.CounterValue, vals.bytesReceived, labels...)
+		if c.usageRates == nil {
+			continue
+		}
+		if rates, ok := c.usageRates.rate(key, *vals, time.Now()); ok {
+			ch <- prometheus.MustNewConstMetric(c.opsRate, prometheus.GaugeValue, rates.ops, labels...)
+			ch <- prometheus.MustNewConstMetric(c.successfulOpsRate, prometheus.GaugeValue, rates.successfulOps, labels...)
+			ch <- prometheus.MustNewConstMetric(c.bytesSentRate, prometheus.GaugeValue, rates.bytesSent, labels...)
+			ch <- prometheus.MustNewConstMetric(c.bytesReceivedRate, prometheus.GaugeValue, rates.bytesReceived, labels...)
+		}
+	}
+}
+
+// collectUsers exports the total size and object count of every user; light mode
+// sums the bucket stats of each user instead of fetching the user's details
+func (c *RADOSGWCollector) collectUsers(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	if c.light {
+		return c.collectUserTotals(ctx, s, ch)
+	}
+	guard := s.guard("users")
+	defer c.emitOtherUsers(guard, ch)
+	return s.eachUser(ctx, c, func(uid string) {
+		user, err := s.user(ctx, c, uid)
+		if errors.Is(err, errUserSkipped) {
+			return
+		}
+		if err != nil {
+			c.logger.Debug("Failed to get user details", "uid", uid, "error", err)
+			s.report.addError(fmt.Errorf("get user %s: %w", uid, err))
+			return
+		}
+		// Kept details come without sizes, which are summed over the buckets instead
+		if s.keptUser(uid) {
+			bytes, objects, err := s.userTotals(ctx, c, uid)
+			if err != nil {
+				c.logger.Debug("Failed to list buckets for user", "uid", uid, "error", err)
+				s.report.addError(fmt.Errorf("list buckets of %s: %w", uid, err))
+				return
+			}
+			c.emitUserTotals(user.ID, &bytes, &objects, guard, ch)
+			return
+		}
+		c.emitUserTotals(user.ID, user.Stat.Size, user.Stat.NumObjects, guard, ch)
+	})
+}
+
+// userTotals sums the size and object count of the buckets of uid, from the
+// cluster-wide bucket listing or, with bucketsPerUser, the user's own
+func (s *scrapeState) userTotals(ctx context.Context, c *RADOSGWCollector, uid string) (bytes, objects uint64, err error) {
+	if !c.bucketsPerUser {
+		owners, err := s.buckets(ctx, c)
+		if err != nil {
+			return 0, 0, err
+		}
+		if t, ok := owners[uid]; ok {
+			return t.bytes, t.objects, nil
+		}
+		return 0, 0, nil
+	}
+	buckets, err := s.bucketsOf(ctx, c, uid)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, b := range buckets {
+		if b.Usage.RgwMain.Size != nil {
+			bytes += *b.Usage.RgwMain.Size
+		}
+		if b.Usage.RgwMain.NumObjects != nil {
+			objects += *b.Usage.RgwMain.NumObjects
+		}
+	}
+	return bytes, objects, nil
+}
+
+// collectUserTotals exports the size and object count of every user summed over
+// the user's buckets
+func (c *RADOSGWCollector) collectUserTotals(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	uids, err := s.users(ctx, c)
+	if err != nil {
+		return err
+	}
+	owners, err := s.buckets(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("users")
+	for _, uid := range uids {
+		// Users seen only in the usage log own no buckets
+		t, ok := owners[uid]
+		if !ok {
+			t = &bucketTotals{}
+		}
+		c.emitUserTotals(uid, &t.bytes, &t.objects, guard, ch)
+	}
+	c.emitOtherUsers(guard, ch)
+	return nil
+}
+
+// emitUserTotals exports the size and object count of a user; users beyond the
+// series limit of guard are folded into its other series
+func (c *RADOSGWCollector) emitUserTotals(uid string, bytes, objects *uint64, guard *seriesGuard, ch chan<- prometheus.Metric) {
+	n := 0
+	for _, v := range []*uint64{bytes, objects} {
+		if v != nil {
+			n++
+		}
+	}
+	if !guard.allow(n) {
+		guard.foldSizes(bytes, nil, objects)
+		return
+	}
+	userLabels := c.userLabelValues(uid)
+	if objects != nil {
+		ch <- prometheus.MustNewConstMetric(c.userTotalObjects, prometheus.GaugeValue, float64(*objects), userLabels...)
+	}
+	if bytes != nil {
+		ch <- prometheus.MustNewConstMetric(c.userTotalBytes, prometheus.GaugeValue, float64(*bytes), userLabels...)
+	}
+}
+
+// emitOtherUsers exports the users folded by guard
+func (c *RADOSGWCollector) emitOtherUsers(guard *seriesGuard, ch chan<- prometheus.Metric) {
+	if sizes, _, ok := guard.other(); ok {
+		c.emitUserTotals(otherLabel, &sizes.bytes, &sizes.objects, nil, ch)
+	}
+}
+
+// emitUserBuckets exports the bucket count of a user; those beyond the series
+// limit of guard are dropped
+func (c *RADOSGWCollector) emitUserBuckets(uid string, n int, guard *seriesGuard, ch chan<- prometheus.Metric) {
+	if !guard.allow(1) {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.userBuckets, prometheus.GaugeValue, float64(n), c.userLabelValues(uid)...)
+}
+
+// emitBucketCounts exports the number of buckets in all and by tenant, given the
+// bucket count of every tenant
+func (c *RADOSGWCollector) emitBucketCounts(tenants map[string]int, ch chan<- prometheus.Metric) {
+	total := 0
+	for _, n := range tenants {
+		total += n
+	}
+	ch <- prometheus.MustNewConstMetric(c.bucketsTotal, prometheus.GaugeValue, float64(total), c.store)
+	for tenant, n := range tenants {
+		ch <- prometheus.MustNewConstMetric(c.tenantBuckets, prometheus.GaugeValue, float64(n), tenant, c.store)
+	}
+}
+
+// collectUserQuotas exports the user quota, the per-bucket quota and the bucket
+// limit of every user
+func (c *RADOSGWCollector) collectUserQuotas(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	guard := s.guard("user_quotas")
+	return s.eachUser(ctx, c, func(uid string) {
+		user, err := s.user(ctx, c, uid)
+		if errors.Is(err, errUserSkipped) {
+			return
+		}
+		if err != nil {
+			c.logger.Debug("Failed to get user details", "uid", uid, "error", err)
+			s.report.addError(fmt.Errorf("get user %s: %w", uid, err))
+			return
+		}
+		userLabels := c.userLabelValues(user.ID)
+		var metrics []prometheus.Metric
+
+		// User Quota
+		if user.UserQuota.Enabled != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userQuotaEnabled, prometheus.GaugeValue, boolToFloat(*user.UserQuota.Enabled), userLabels...))
+		}
+		if user.UserQuota.MaxSizeKb != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userQuotaMaxSizeBytes, prometheus.GaugeValue, float64(*user.UserQuota.MaxSizeKb*1024), userLabels...))
+		}
+		if user.UserQuota.MaxObjects != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userQuotaMaxObjects, prometheus.GaugeValue, float64(*user.UserQuota.MaxObjects), userLabels...))
+		}
+
+		// Bucket Quota (per-user)
+		if user.BucketQuota.Enabled != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userBucketQuotaEnabled, prometheus.GaugeValue, boolToFloat(*user.BucketQuota.Enabled), userLabels...))
+		}
+		if user.BucketQuota.MaxSizeKb != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userBucketQuotaMaxSizeBytes, prometheus.GaugeValue, float64(*user.BucketQuota.MaxSizeKb*1024), userLabels...))
+		}
+		if user.BucketQuota.MaxObjects != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userBucketQuotaMaxObjects, prometheus.GaugeValue, float64(*user.BucketQuota.MaxObjects), userLabels...))
+		}
+		if user.MaxBuckets != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userMaxBuckets, prometheus.GaugeValue, float64(*user.MaxBuckets), userLabels...))
+		}
+
+		// Quotas do not add up, those beyond the series limit are dropped
+		if !guard.allow(len(metrics)) {
+			return
+		}
+		for _, m := range metrics {
+			ch <- m
+		}
+	})
+}
+
+// collectUserInfo exports whether every user is suspended, its subusers with
+// their permissions and its S3 and Swift keys
+func (c *RADOSGWCollector) collectUserInfo(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	guard := s.guard("user_info")
+	return s.eachUser(ctx, c, func(uid string) {
+		user, err := s.user(ctx, c, uid)
+		if errors.Is(err, errUserSkipped) {
+			return
+		}
+		if err != nil {
+			c.logger.Debug("Failed to get user details", "uid", uid, "error", err)
+			s.report.addError(fmt.Errorf("get user %s: %w", uid, err))
+			return
+		}
+		userLabels := c.userLabelValues(user.ID)
+		var metrics []prometheus.Metric
+		if user.Suspended != nil {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userSuspended, prometheus.GaugeValue, float64(*user.Suspended), userLabels...))
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.userSubusers, prometheus.GaugeValue, float64(len(user.Subusers)), userLabels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.userAccessKeys, prometheus.GaugeValue, float64(len(user.Keys)), userLabels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.userSwiftKeys, prometheus.GaugeValue, float64(len(user.SwiftKeys)), userLabels...))
+		for _, sub := range user.Subusers {
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.userSubuserInfo, prometheus.GaugeValue, 1, slices.Concat(userLabels, []string{sub.Name, string(sub.Access)})...))
+		}
+		if !guard.allow(len(metrics)) {
+			return
+		}
+		for _, m := range metrics {
+			ch <- m
+		}
+	})
+}
+
+// collectBucketStats exports the size and object count of every bucket, listed
+// with one cluster-wide request or, with bucketsPerUser, one request per user
+func (c *RADOSGWCollector) collectBucketStats(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	if c.bucketsPerUser && !c.light {
+		guard := s.guard("bucket_stats")
+		defer c.emitOtherBuckets(guard, ch)
+		var mu sync.Mutex
+		tenants := make(map[string]int)
+		complete := true
+		err := s.eachUser(ctx, c, func(uid string) {
+			if c.maxBuckets > 0 && s.bucketsTaken.Load() >= int64(c.maxBuckets) {
+				s.truncatedBuckets.Store(true)
+				return
+			}
+			buckets, err := s.bucketsOf(ctx, c, uid)
+			if err != nil {
+				c.logger.Debug("Failed to list buckets for user", "uid", uid, "error", err)
+				s.report.addError(fmt.Errorf("list buckets of %s: %w", uid, err))
+				mu.Lock()
+				complete = false
+				mu.Unlock()
+				return
+			}
+			c.emitUserBuckets(uid, len(buckets), guard, ch)
+			mu.Lock()
+			for _, b := range buckets {
+				tenants[b.Tenant]++
+			}
+			mu.Unlock()
+			taken := 0
+			for _, b := range buckets {
+				if c.expiry != nil {
+					c.expiry.observeBucket(b.Owner, b.Bucket.Bucket)
+				}
+				if !s.takeBucket(c) {
+					break
+				}
+				taken++
+				c.emitBucket(b, guard, ch)
+			}
+			s.report.count(&s.report.Buckets, taken)
+		})
+		// Users left out or failing to list would make the counts too low
+		if err == nil && complete && !s.truncatedUsers.Load() {
+			c.emitBucketCounts(tenants, ch)
+		}
+		return err
+	}
+
+	// The buckets are exported while the shared listing is read
+	_, err := s.buckets(ctx, c)
+	return err
+}
+
+// instanceInfo — what the instance collectors export of one bucket instance
+type instanceInfo struct {
+	bucketRef
+	resharding     bool
+	reshardTarget  uint64
+	lifecycleRules uint32
+	cors           bool
+	// Algorithm of the default encryption, empty when none is configured
+	encryption string
+	// Whether the bucket policy allows anyone
+	publicPolicy  bool
+	notifications uint32
+	syncDisabled  bool
+	ratelimit     ratelimit
+}
+
+// bucketInstances reads the metadata of every listed bucket instance on first
+// use; instances failing to read are reported and left out
+func (s *scrapeState) bucketInstances(ctx context.Context, c *RADOSGWCollector) ([]instanceInfo, error) {
+	s.instancesOnce.Do(func() {
+		if _, err := s.buckets(ctx, c); err != nil {
+			s.instancesErr = err
+			return
+		}
+		var mu sync.Mutex
+		s.instancesErr = forEach(ctx, c.concurrency, s.bucketRefs, func(b bucketRef) {
+			key := b.key()
+			var instance bucketInstance
+			if err := adminGet(ctx, c.client, "/metadata/bucket.instance", url.Values{"key": {key + ":" + b.id}}, &instance); err != nil {
+				c.logger.Debug("Failed to get bucket instance", "bucket", key, "error", err)
+				s.report.addError(fmt.Errorf("get bucket instance %s: %w", key, err))
+				return
+			}
+			info := instanceInfo{
+				bucketRef:      b,
+				lifecycleRules: instance.lifecycleRules(),
+				cors:           instance.attr("user.rgw.cors") != nil,
+				encryption:     instance.encryption(),
+				publicPolicy:   publicPolicy(instance.attr("user.rgw.iam-policy")),
+				notifications:  instance.notifications(),
+				syncDisabled:   instance.Data.BucketInfo.Flags&bucketDataSyncDisabled != 0,
+				ratelimit:      instance.ratelimit(),
+			}
+			info.resharding, info.reshardTarget = instance.resharding()
+			mu.Lock()
+			s.instances = append(s.instances, info)
+			mu.Unlock()
+		})
+	})
+	return s.instances, s.instancesErr
+}
+
+// collectBucketReshard exports whether the index of every listed bucket is being
+// resharded
+func (c *RADOSGWCollector) collectBucketReshard(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_reshard")
+	for _, b := range instances {
+		n := 1
+		if b.reshardTarget > 0 {
+			n++
+		}
+		if !guard.allow(n) {
+			continue
+		}
+		labels := c.bucketLabelValues(b.bucket, b.tenant, b.owner)
+		ch <- prometheus.MustNewConstMetric(c.bucketResharding, prometheus.GaugeValue, boolToFloat(b.resharding), labels...)
+		if b.reshardTarget > 0 {
+			ch <- prometheus.MustNewConstMetric(c.bucketReshardTarget, prometheus.GaugeValue, float64(b.reshardTarget), labels...)
+		}
+	}
+	return nil
+}
+
+// collectBucketLifecycle exports the number of lifecycle rules of every listed
+// bucket, 0 for buckets without a lifecycle configuration
+func (c *RADOSGWCollector) collectBucketLifecycle(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_lifecycle")
+	for _, b := range instances {
+		if !guard.allow(1) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.bucketLifecycleRules, prometheus.GaugeValue, float64(b.lifecycleRules), c.bucketLabelValues(b.bucket, b.tenant, b.owner)...)
+	}
+	return nil
+}
+
+// collectBucketCORS exports whether a CORS configuration is set on every listed
+// bucket
+func (c *RADOSGWCollector) collectBucketCORS(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_cors")
+	for _, b := range instances {
+		if !guard.allow(1) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.bucketCORS, prometheus.GaugeValue, boolToFloat(b.cors), c.bucketLabelValues(b.bucket, b.tenant, b.owner)...)
+	}
+	return nil
+}
+
+// collectBucketEncryption exports whether default server-side encryption is
+// configured on every listed bucket, along with its algorithm
+func (c *RADOSGWCollector) collectBucketEncryption(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_encryption")
+	for _, b := range instances {
+		if !guard.allow(1) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.bucketEncryption, prometheus.GaugeValue, boolToFloat(b.encryption != ""), c.bucketLabelValues(b.bucket, b.tenant, b.owner, b.encryption)...)
+	}
+	return nil
+}
+
+// collectBucketNotifications exports the number of notification configurations
+// of every listed bucket
+func (c *RADOSGWCollector) collectBucketNotifications(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_notifications")
+	for _, b := range instances {
+		if !guard.allow(1) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.bucketNotifications, prometheus.GaugeValue, float64(b.notifications), c.bucketLabelValues(b.bucket, b.tenant, b.owner)...)
+	}
+	return nil
+}
+
+// collectBucketRatelimit exports the rate limit set on every listed bucket
+func (c *RADOSGWCollector) collectBucketRatelimit(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_ratelimit")
+	for _, b := range instances {
+		if !guard.allow(5) {
+			continue
+		}
+		rl := b.ratelimit
+		ch <- prometheus.MustNewConstMetric(c.bucketRatelimitEnabled, prometheus.GaugeValue, boolToFloat(rl.enabled), c.bucketLabelValues(b.bucket, b.tenant, b.owner)...)
+		ch <- prometheus.MustNewConstMetric(c.bucketRatelimitOps, prometheus.GaugeValue, float64(rl.maxReadOps), c.bucketLabelValues(b.bucket, b.tenant, b.owner, "read")...)
+		ch <- prometheus.MustNewConstMetric(c.bucketRatelimitOps, prometheus.GaugeValue, float64(rl.maxWriteOps), c.bucketLabelValues(b.bucket, b.tenant, b.owner, "write")...)
+		ch <- prometheus.MustNewConstMetric(c.bucketRatelimitBytes, prometheus.GaugeValue, float64(rl.maxReadBytes), c.bucketLabelValues(b.bucket, b.tenant, b.owner, "read")...)
+		ch <- prometheus.MustNewConstMetric(c.bucketRatelimitBytes, prometheus.GaugeValue, float64(rl.maxWriteBytes), c.bucketLabelValues(b.bucket, b.tenant, b.owner, "write")...)
+	}
+	return nil
+}
+
+// collectBucketSync exports whether multisite data sync is enabled for every
+// listed bucket
+func (c *RADOSGWCollector) collectBucketSync(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_sync")
+	for _, b := range instances {
+		if !guard.allow(1) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.bucketSyncEnabled, prometheus.GaugeValue, boolToFloat(!b.syncDisabled), c.bucketLabelValues(b.bucket, b.tenant, b.owner)...)
+	}
+	return nil
+}
+
+// collectBucketSyncBacklog exports how many index shards of every listed bucket
+// hold changes not yet trimmed from the bucket index log and the time of the
+// oldest one. The log is trimmed once all peer zones have synced its entries, so
+// what is left approximates the changes still to replicate
+func (c *RADOSGWCollector) collectBucketSyncBacklog(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	if _, err := s.buckets(ctx, c); err != nil {
+		return err
+	}
+	guard := s.guard("bucket_sync_backlog")
+	return forEach(ctx, c.concurrency, s.bucketRefs, func(b bucketRef) {
+		instance := b.key() + ":" + b.id
+		// Unsharded indexes of old buckets are listed without a shard id
+		shards := []string{instance}
+		if b.shards > 0 {
+			shards = shards[:0]
+			for i := range b.shards {
+				shards = append(shards, instance+":"+strconv.FormatUint(i, 10))
+			}
+		}
+		var behind int
+		var oldest time.Time
+		for _, shard := range shards {
+			var entries []struct {
+				Timestamp string `json:"timestamp"`
+			}
+			if err := adminGet(ctx, c.client, "/log", url.Values{"type": {"bucket-index"}, "bucket-instance": {shard}, "max-entries": {"1"}}, &entries); err != nil {
+				c.logger.Debug("Failed to get bucket index log", "bucket", b.key(), "error", err)
+				s.report.addError(fmt.Errorf("get bucket index log %s: %w", shard, err))
+				return
+			}
+			if len(entries) == 0 {
+				continue
+			}
+			behind++
+			if t, ok := parseBucketTime(entries[0].Timestamp); ok && (oldest.IsZero() || t.Before(oldest)) {
+				oldest = t
+			}
+		}
+		n := 1
+		if !oldest.IsZero() {
+			n++
+		}
+		if !guard.allow(n) {
+			return
+		}
+		labels := c.bucketLabelValues(b.bucket, b.tenant, b.owner)
+		ch <- prometheus.MustNewConstMetric(c.bucketSyncBacklogShards, prometheus.GaugeValue, float64(behind), labels...)
+		if !oldest.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.bucketSyncOldestChange, prometheus.GaugeValue, float64(oldest.Unix()), labels...)
+		}
+	})
+}
+
+// collectBucketAccess exports whether every listed bucket is open to anonymous
+// users, either by its bucket policy or by an ACL grant to all users, and its ACL
+// grants by grantee, fetching the ACL of each bucket
+func (c *RADOSGWCollector) collectBucketAccess(ctx context.Context, s *scrapeState, ch chan<- prometheus.Metric) error {
+	instances, err := s.bucketInstances(ctx, c)
+	if err != nil {
+		return err
+	}
+	guard := s.guard("bucket_access")
+	return forEach(ctx, c.concurrency, instances, func(b instanceInfo) {
+		var acl admin.Policy
+		if err := adminGet(ctx, c.client, "/bucket", url.Values{"policy": {""}, "bucket": {b.key()}}, &acl); err != nil {
+			c.logger.Debug("Failed to get bucket ACL", "bucket", b.key(), "error", err)
+			s.report.addError(fmt.Errorf("get bucket ACL %s: %w", b.key(), err))
+			return
+		}
+		grants := aclGrants(acl)
+		if !guard.allow(1 + len(aclGrantees)) {
+			return
+		}
+		public := b.publicPolicy || publicACL(acl)
+		ch <- prometheus.MustNewConstMetric(c.bucketPublicAccess, prometheus.GaugeValue, boolToFloat(public), c.bucketLabelValues(b.bucket, b.tenant, b.owner)...)
+		for _, grantee := range aclGrantees {
+			ch <- prometheus.MustNewConstMetric(c.bucketACLGrants, prometheus.GaugeValue, float64(grants[grantee]), c.bucketLabelValues(b.bucket, b.tenant, b.owner, grantee)...)
+		}
+	})
+}
+
+// ACL grantee types and groups, and the permissions of Ceph
+const (
+	aclTypeUser           = 0
+	aclTypeEmail          = 1
+	aclTypeGroup          = 2
+	aclGroupAllUsers      = 1
+	aclGroupAuthenticated = 2
+	aclPermReadWrite      = 0x01 | 0x02
+)
+
+// aclGrantees — the grantee label values of ACL grants, always all exported
+var aclGrantees = []string{"owner", "user", "all_users", "authenticated_users"}
+
+// aclGrants counts the ACL grants by grantee; grants by HTTP referer are left out
+func aclGrants(acl admin.Policy) map[string]int {
+	grants := make(map[string]int, len(aclGrantees))
+	for _, g := range acl.ACL.GrantMap {
+		switch {
+		case g.Grant.Type.Type == aclTypeUser && g.Grant.ID == acl.Owner.ID:
+			grants["owner"]++
+		case g.Grant.Type.Type == aclTypeUser || g.Grant.Type.Type == aclTypeEmail:
+			grants["user"]++
+		case g.Grant.Type.Type == aclTypeGroup && g.Grant.Group != nil && *g.Grant.Group == aclGroupAllUsers:
+			grants["all_users"]++
+		case g.Grant.Type.Type == aclTypeGroup && g.Grant.Group != nil && *g.Grant.Group == aclGroupAuthenticated:
+			grants["authenticated_users"]++
+		}
+	}
+	return grants
+}
+
+// publicACL reports whether the ACL lets all users, anonymous ones included, read
+// or write the bucket
+func publicACL(acl admin.Policy) bool {
+	for _, g := range acl.ACL.GrantMap {
+		if g.Grant.Type.Type == aclTypeGroup && g.Grant.Group != nil && *g.Grant.Group == aclGroupAllUsers && g.Grant.Permission.Flags&aclPermReadWrite != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// policyStatement — the parts of a bucket policy statement telling whom it allows
+type policyStatement struct {
+	Effect    string          `json:"Effect"`
+	Principal json.RawMessage `json:"Principal"`
+	Condition json.RawMessage `json:"Condition"`
+}
+
+// publicPolicy reports whether the bucket policy doc allows anyone without a
+// condition; statements restricted by a condition, such as to source addresses,
+// are not counted as public
+func publicPolicy(doc []byte) bool {
+	if doc == nil {
+		return false
+	}
+	var policy struct {
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if json.Unmarshal(doc, &policy) != nil {
+		return false
+	}
+	// Statement is either a list or a single statement
+	var statements []policyStatement
+	if json.Unmarshal(policy.Statement, &statements) != nil {
+		var one policyStatement
+		if json.Unmarshal(policy.Statement, &one) != nil {
+			return false
+		}
+		statements = []policyStatement{one}
+	}
+	for _, st := range statements {
+		if st.Effect == "Allow" && len(st.Condition) == 0 && anyPrincipal(st.Principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyPrincipal reports whether a policy principal is everyone: "*" itself, or
+// "*" among the AWS principals
+func anyPrincipal(principal json.RawMessage) bool {
+	var name string
+	if json.Unmarshal(principal, &name) == nil {
+		return name == "*"
+	}
+	var byType map[string]json.RawMessage
+	if json.Unmarshal(principal, &byType) != nil {
+		return false
+	}
+	aws, ok := byType["AWS"]
+	if !ok {
+		return false
+	}
+	if json.Unmarshal(aws, &name) == nil {
+		return name == "*"
+	}
+	var names []string
+	if json.Unmarshal(aws, &names) != nil {
+		return false
+	}
+	for _, n := range names {
+		if n == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketInstance — the parts of a bucket instance's metadata the instance
+// collectors read. Releases since Reef describe index resharding in layout, older
+// ones only in reshard_status; the S3 configurations of the bucket are kept in
+// attrs, Ceph-encoded
+type bucketInstance struct {
+	Data struct {
+		Attrs []struct {
+			Key string `json:"key"`
+			// Dumped base64-encoded
+			Val []byte `json:"val"`
+		} `json:"attrs"`
+		BucketInfo struct {
+			Flags         uint32          `json:"flags"`
+			ReshardStatus json.RawMessage `json:"reshard_status"`
+			Layout        struct {
+				Resharding  string `json:"resharding"`
+				TargetIndex *struct {
+					Layout struct {
+						Normal struct {
+							NumShards uint64 `json:"num_shards"`
+						} `json:"normal"`
+					} `json:"layout"`
+				} `json:"target_index"`
+			} `json:"layout"`
+		} `json:"bucket_info"`
+	} `json:"data"`
+}
+
+// resharding reports whether the bucket index is being resharded and the shard
+// count it is resharded to, 0 when unknown
+func (b *bucketInstance) resharding() (bool, uint64) {
+	info := b.Data.BucketInfo
+	if info.Layout.Resharding != "" {
+		if info.Layout.Resharding == "None" {
+			return false, 0
+		}
+		if info.Layout.TargetIndex != nil {
+			return true, info.Layout.TargetIndex.Layout.Normal.NumShards
+		}
+		return true, 0
+	}
+	// reshard_status is 1 while resharding, dumped as a number or a name
+	var status int
+	if json.Unmarshal(info.ReshardStatus, &status) == nil {
+		return status == 1, 0
+	}
+	var name string
+	if json.Unmarshal(info.ReshardStatus, &name) == nil {
+		return name == "in-progress" || name == "InProgress", 0
+	}
+	return false, 0
+}
+
+// bucketDataSyncDisabled — the bucket flag set by radosgw-admin bucket sync disable
+const bucketDataSyncDisabled = 0x8
+
+// attr returns the value of the named bucket attribute, nil when it is not set
+func (b *bucketInstance) attr(key string) []byte {
+	for _, a := range b.Data.Attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return nil
+}
+
+// lifecycleRules returns the number of rules of the lifecycle configuration. The
+// user.rgw.lc attribute encodes it as a struct header of 6 bytes followed by the
+// rule map, which starts with its 32-bit little-endian size
+func (b *bucketInstance) lifecycleRules() uint32 {
+	lc := b.attr("user.rgw.lc")
+	if len(lc) < 10 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(lc[6:10])
+}
+
+// notifications returns the number of notification configurations. Since Squid
+// the user.rgw.bucket-notification attribute keeps them, encoded like the
+// lifecycle rules as a struct header of 6 bytes followed by a map of its 32-bit
+// little-endian size; earlier releases keep them outside the bucket
+func (b *bucketInstance) notifications() uint32 {
+	topics := b.attr("user.rgw.bucket-notification")
+	if len(topics) < 10 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(topics[6:10])
+}
+
+// encryption returns the algorithm of the default encryption, empty when none is
+// configured and "unknown" when it cannot be decoded. The user.rgw.sse-s3.policy
+// attribute encodes a struct header of 6 bytes, a byte telling whether a rule
+// exists and then the algorithm as a string with its 32-bit little-endian length
+func (b *bucketInstance) encryption() string {
+	policy := b.attr("user.rgw.sse-s3.policy")
+	if policy == nil {
+		return ""
+	}
+	if len(policy) < 7 {
+		return "unknown"
+	}
+	if policy[6] == 0 {
+		return ""
+	}
+	if len(policy) < 11 {
+		return "unknown"
+	}
+	n := binary.LittleEndian.Uint32(policy[7:11])
+	if n == 0 || uint64(len(policy)-11) < uint64(n) {
+		return "unknown"
+	}
+	return string(policy[11 : 11+n])
+}
+
+// ratelimit — a rate limit of RGW, per minute and per RGW instance; 0 is no limit
+type ratelimit struct {
+	enabled       bool
+	maxReadOps    int64
+	maxWriteOps   int64
+	maxReadBytes  int64
+	maxWriteBytes int64
+}
+
+// ratelimit returns the rate limit set on the bucket, disabled when none is. The
+// user.rgw.ratelimit attribute encodes a struct header of 6 bytes, the write and
+// read ops, the write and read bytes as 64-bit little-endian numbers and then a
+// byte telling whether it is enabled
+func (b *bucketInstance) ratelimit() ratelimit {
+	attr := b.attr("user.rgw.ratelimit")
+	if len(attr) < 39 {
+		return ratelimit{}
+	}
+	return ratelimit{
+		enabled:       attr[38] != 0,
+		maxWriteOps:   int64(binary.LittleEndian.Uint64(attr[6:14])),
+		maxReadOps:    int64(binary.LittleEndian.Uint64(attr[14:22])),
+		maxWriteBytes: int64(binary.LittleEndian.Uint64(attr[22:30])),
+		maxReadBytes:  int64(binary.LittleEndian.Uint64(attr[30:38])),
+	}
+}
+
+// emitBucket exports the usage, quota and index shards of a bucket; buckets beyond
+// the series limit of guard are folded into its other series
+func (c *RADOSGWCollector) emitBucket(b bucketStats, guard *seriesGuard, ch chan<- prometheus.Metric) {
+	c.emitBucketSizes(b.Bucket.Bucket, b.Tenant, b.Owner, b.Usage.RgwMain.SizeActual, b.Usage.RgwMain.SizeUtilized, b.Usage.RgwMain.NumObjects, guard, ch)
+	c.emitBucketInfo(b, guard, ch)
+}
+
+// emitBucketInfo exports the owner when it is not a label, the placement, the
+// zonegroup, the index type, the instance and the quota set on the bucket itself,
+// its index shard count and load, creation and modification times, versioning
+// and object lock state, what incomplete multipart uploads hold, its index
+// entries without data and the objects moved to cloud tiers; those beyond the
+// series limit of guard are dropped
+func (c *RADOSGWCollector) emitBucketInfo(b bucketStats, guard *seriesGuard, ch chan<- prometheus.Metric) {
+	labels := c.bucketLabelValues(b.Bucket.Bucket, b.Tenant, b.Owner)
+	var metrics []prometheus.Metric
+	if c.ownerInfo {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketOwnerInfo, prometheus.GaugeValue, 1, b.Bucket.Bucket, b.Owner, c.store, b.Tenant))
+	}
+	if b.PlacementRule != "" {
+		placement, class := splitPlacementRule(b.PlacementRule)
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketInfo, prometheus.GaugeValue, 1, c.bucketLabelValues(b.Bucket.Bucket, b.Tenant, b.Owner, placement, class, b.Zonegroup, b.IndexType, b.ID, b.Marker)...))
+	}
+	if b.NumShards != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketShards, prometheus.GaugeValue, float64(*b.NumShards), labels...))
+		if *b.NumShards > 0 && b.Usage.RgwMain.NumObjects != nil {
+			perShard := float64(*b.Usage.RgwMain.NumObjects) / float64(*b.NumShards)
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketObjectsPerShard, prometheus.GaugeValue, perShard, labels...))
+		}
+	}
+	if b.CreationTime != nil && !b.CreationTime.IsZero() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketCreated, prometheus.GaugeValue, float64(b.CreationTime.Unix()), labels...))
+	}
+	if mtime, ok := parseBucketTime(b.Mtime); ok {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketModified, prometheus.GaugeValue, float64(mtime.Unix()), labels...))
+	}
+	if enabled, suspended, ok := b.versioning(); ok {
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(c.bucketVersioning, prometheus.GaugeValue, boolToFloat(enabled), labels...),
+			prometheus.MustNewConstMetric(c.bucketVersioningPaused, prometheus.GaugeValue, boolToFloat(suspended), labels...),
+		)
+	}
+	if b.ObjectLockEnabled != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketObjectLock, prometheus.GaugeValue, boolToFloat(*b.ObjectLockEnabled), labels...))
+	}
+	if b.BucketQuota.Enabled != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketQuotaEnabled, prometheus.GaugeValue, boolToFloat(*b.BucketQuota.Enabled), labels...))
+	}
+	if b.BucketQuota.MaxSizeKb != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketQuotaMaxSizeBytes, prometheus.GaugeValue, float64(*b.BucketQuota.MaxSizeKb*1024), labels...))
+	}
+	if b.BucketQuota.MaxObjects != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketQuotaMaxObjects, prometheus.GaugeValue, float64(*b.BucketQuota.MaxObjects), labels...))
+	}
+	// rgw.multimeta counts the parts of uploads neither completed nor aborted
+	if b.Usage.RgwMultimeta.SizeActual != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketMultipartBytes, prometheus.GaugeValue, float64(*b.Usage.RgwMultimeta.SizeActual), labels...))
+	}
+	if b.Usage.RgwMultimeta.NumObjects != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketMultipartObjects, prometheus.GaugeValue, float64(*b.Usage.RgwMultimeta.NumObjects), labels...))
+	}
+	// rgw.none counts index entries such as delete markers and placeholders
+	if b.Usage.RgwNone.SizeActual != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketIndexOnlyBytes, prometheus.GaugeValue, float64(*b.Usage.RgwNone.SizeActual), labels...))
+	}
+	if b.Usage.RgwNone.NumObjects != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketIndexOnlyObjects, prometheus.GaugeValue, float64(*b.Usage.RgwNone.NumObjects), labels...))
+	}
+	// rgw.cloudtiered counts the objects transitioned to a cloud tier, whose data
+	// left the cluster; size is what the objects held before the transition
+	if b.Usage.RgwCloudtiered.Size != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketTieredBytes, prometheus.GaugeValue, float64(*b.Usage.RgwCloudtiered.Size), labels...))
+	}
+	if b.Usage.RgwCloudtiered.NumObjects != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(c.bucketTieredObjects, prometheus.GaugeValue, float64(*b.Usage.RgwCloudtiered.NumObjects), labels...))
+	}
+	if !guard.allow(len(metrics)) {
+		return
+	}
+	for _, m := range metrics {
+		ch <- m
+	}
+}
+
+// splitPlacementRule splits a placement rule such as "default-placement/COLD"
+// into the placement target and the storage class, STANDARD when it names none
+func splitPlacementRule(rule string) (placement, class string) {
+	placement, class, _ = strings.Cut(rule, "/")
+	if class == "" {
+		class = "STANDARD"
+	}
+	return placement, class
+}
+
+// bucketTimeLayouts — formats of the times in bucket stats; RGW writes a space
+// instead of the T before Quincy
+var bucketTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00"}
+
+// parseBucketTime parses a time of bucket stats, reporting false when it is
+// missing or unknown
+func parseBucketTime(value string) (time.Time, bool) {
+	for _, layout := range bucketTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil && !t.IsZero() {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// emitBucketSizes exports the size, the size after compression and the object
+// count of a bucket
+func (c *RADOSGWCollector) emitBucketSizes(bucket, tenant, owner string, bytes, utilized, objects *uint64, guard *seriesGuard, ch chan<- prometheus.Metric) {
+	n := 0
+	for _, v := range []*uint64{bytes, utilized, objects} {
+		if v != nil {
+			n++
+		}
+	}
+	if !guard.allow(n) {
+		guard.foldSizes(bytes, utilized, objects)
+		return
+	}
+	labels := c.bucketLabelValues(bucket, tenant, owner, "bucket_total")
+	if objects != nil {
+		ch <- prometheus.MustNewConstMetric(c.bucketUsageObjects, prometheus.GaugeValue, float64(*objects), labels...)
+	}
+	if bytes != nil {
+		ch <- prometheus.MustNewConstMetric(c.bucketUsageBytes, prometheus.GaugeValue, float64(*bytes), labels...)
+	}
+	if utilized != nil {
+		ch <- prometheus.MustNewConstMetric(c.bucketUtilizedBytes, prometheus.GaugeValue, float64(*utilized), labels...)
+	}
+}
+
+// emitOtherBuckets exports the buckets folded by guard
+func (c *RADOSGWCollector) emitOtherBuckets(guard *seriesGuard, ch chan<- prometheus.Metric) {
+	if sizes, _, ok := guard.other(); ok {
+		c.emitBucketSizes(otherLabel, otherLabel, otherLabel, &sizes.bytes, &sizes.utilized, &sizes.objects, nil, ch)
+	}
+}
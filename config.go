@@ -0,0 +1,845 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/exporter-toolkit/web"
+	"go.yaml.in/yaml/v2"
+)
+
+// Config — exporter settings resolved from the environment
+type Config struct {
+	Endpoint        string   `yaml:"endpoint"`
+	Endpoints       []string `yaml:"endpoints"`
+	AccessKey       string   `yaml:"access_key" secret:"true"`
+	SecretKey       string   `yaml:"secret_key" secret:"true"`
+	Store           string   `yaml:"store"`
+	StoreAutodetect bool     `yaml:"store_autodetect"`
+	Insecure        bool     `yaml:"insecure_skip_verify"`
+	AdminPath       string   `yaml:"admin_path"`
+
+	// Gateway discovery; when DiscoveryDNSName or DiscoveryK8sService is set, the
+	// host of Endpoint is replaced by a reachable gateway found there
+	DiscoveryDNSName    string `yaml:"discovery_dns_name"`
+	DiscoveryDNSType    string `yaml:"discovery_dns_type"`
+	DiscoveryDNSPort    string `yaml:"discovery_dns_port"`
+	DiscoveryK8sService string `yaml:"discovery_kubernetes_service"`
+	DiscoveryK8sPort    string `yaml:"discovery_kubernetes_port"`
+
+	// Ceph service map discovery through librados, only in builds with -tags rados
+	DiscoveryCephConf    string `yaml:"discovery_ceph_conf"`
+	DiscoveryCephUser    string `yaml:"discovery_ceph_user"`
+	DiscoveryCephKeyring string `yaml:"discovery_ceph_keyring"`
+	DiscoveryCephZone    string `yaml:"discovery_ceph_zone"`
+
+	DiscoveryInterval time.Duration `yaml:"discovery_interval"`
+
+	// TLS settings of the RGW client
+	TLSMinVersion   tlsVersion   `yaml:"rgw_tls_min_version"`
+	TLSCipherSuites cipherSuites `yaml:"rgw_tls_cipher_suites"`
+
+	// Extra request headers for admin API calls
+	UserAgent    string      `yaml:"http_user_agent"`
+	HostHeader   string      `yaml:"http_host_header"`
+	ExtraHeaders http.Header `yaml:"http_headers"`
+
+	// HTTP client tuning for admin API calls
+	HTTPTimeout             time.Duration `yaml:"http_timeout"`
+	HTTPDialTimeout         time.Duration `yaml:"http_dial_timeout"`
+	HTTPKeepAlive           time.Duration `yaml:"http_keep_alive"`
+	HTTPMaxIdleConns        int           `yaml:"http_max_idle_conns"`
+	HTTPMaxIdleConnsPerHost int           `yaml:"http_max_idle_conns_per_host"`
+	HTTPIdleConnTimeout     time.Duration `yaml:"http_idle_conn_timeout"`
+
+	// Metrics listener; WebSystemdSocket replaces ListenAddresses with LISTEN_FDS sockets,
+	// WebConfigFile is the exporter-toolkit web config (TLS, basic auth)
+	Port                 string   `yaml:"metrics_port"`
+	ListenAddresses      []string `yaml:"web_listen_addresses"`
+	WebSystemdSocket     bool     `yaml:"web_systemd_socket"`
+	TelemetryPath        string   `yaml:"web_telemetry_path"`
+	WebConfigFile        string   `yaml:"web_config_file"`
+	WebRequireClientCert bool     `yaml:"web_require_client_cert"`
+	WebEnablePprof       bool     `yaml:"web_enable_pprof"`
+	WebAccessLog         bool     `yaml:"web_access_log"`
+
+	// Plain HTTP listener serving only /healthz, /readyz and pprof; when set,
+	// those endpoints are removed from the metrics listener
+	HealthListenAddress string `yaml:"web_health_listen_address"`
+
+	// promhttp handler options
+	WebMaxRequests   int           `yaml:"web_max_requests"`
+	WebTimeout       time.Duration `yaml:"web_timeout"`
+	WebErrorHandling string        `yaml:"web_error_handling"`
+	WebCompression   string        `yaml:"web_compression"`
+
+	// Per-client /metrics rate limit in requests per second; disabled when 0
+	WebRateLimit float64 `yaml:"web_rate_limit"`
+	WebRateBurst int     `yaml:"web_rate_burst"`
+
+	// CORS for non-metrics endpoints; disabled when WebCORSOrigins is empty
+	WebCORSOrigins []string `yaml:"web_cors_origins"`
+	WebCORSMethods []string `yaml:"web_cors_methods"`
+
+	// Background collection period; scrapes collect synchronously when 0
+	ScrapeInterval time.Duration `yaml:"scrape_interval"`
+
+	// Collections longer than this are logged as a warning; never when 0
+	SlowScrapeWarning time.Duration `yaml:"slow_scrape_warning"`
+
+	// Upper bounds of the radosgw_collection_duration_seconds histogram buckets
+	ScrapeDurationBuckets []float64 `yaml:"scrape_duration_buckets"`
+
+	// Overlapping scrapes run their collections one after another instead of sharing one
+	SerializeScrapes bool `yaml:"serialize_scrapes"`
+
+	// File keeping the last background collection across restarts; none when empty
+	CacheFile string `yaml:"cache_file"`
+
+	// Longest wait for a first successful collection before serving; no warm-up when 0
+	WarmupTimeout time.Duration `yaml:"warmup_timeout"`
+
+	// Deadline of every collection, including background ones; none when 0
+	ScrapeTimeout time.Duration `yaml:"scrape_timeout"`
+
+	// Failed collections in a row after which radosgw_up reports 0
+	DownAfterFailures int `yaml:"down_after_failures"`
+
+	// Circuit breaker: after BreakerFailures failed collections in a row RGW is not
+	// called for BreakerCooldown; disabled when 0
+	BreakerFailures int           `yaml:"breaker_failures"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+
+	// User details and quotas are fetched every UserDetailsEvery collections and reused in between
+	UserDetailsEvery int `yaml:"user_details_every"`
+
+	// Most series every sub-collector exports individually, the rest is summed into
+	// "other"; unlimited when 0
+	SeriesLimit int `yaml:"series_limit"`
+
+	// Most users and buckets processed in one collection, the rest is left out; unlimited when 0
+	MaxUsers   int `yaml:"limit_max_users"`
+	MaxBuckets int `yaml:"limit_max_buckets"`
+
+	// How long users whose details RGW refused are skipped; retried every scrape when 0
+	UserFailureTTL time.Duration `yaml:"user_failure_ttl"`
+
+	// How long the data of the last successful collection replaces that of failed ones; disabled when 0
+	StaleMaxAge time.Duration `yaml:"stale_max_age"`
+
+	// Subtracted from X-Prometheus-Scrape-Timeout-Seconds to get the collection deadline
+	ScrapeTimeoutOffset time.Duration `yaml:"scrape_timeout_offset"`
+
+	// Leader election between replicas: "", "kubernetes" (Lease) or "file" (flock)
+	LeaderElection       string        `yaml:"leader_election"`
+	LeaderIdentity       string        `yaml:"leader_identity"`
+	LeaderLeaseName      string        `yaml:"leader_lease_name"`
+	LeaderLeaseNamespace string        `yaml:"leader_lease_namespace"`
+	LeaderLeaseDuration  time.Duration `yaml:"leader_lease_duration"`
+	LeaderLockFile       string        `yaml:"leader_lock_file"`
+
+	// Fetch only the recent hours of the usage log and keep the totals of older ones
+	UsageIncremental bool `yaml:"usage_incremental"`
+
+	// Keep usage counters monotonic when they go down, as after a usage trim
+	UsageResetCorrection bool `yaml:"usage_reset_correction"`
+
+	// Also export per-second usage rates between background collections
+	UsageRates bool `yaml:"usage_rates"`
+
+	// Successful collections after which the usage kept for buckets and users RGW
+	// no longer reports is forgotten; kept forever when 0
+	SeriesTTL int `yaml:"series_ttl"`
+
+	// List bucket stats once per user instead of with one cluster-wide request
+	BucketStatsPerUser bool `yaml:"bucket_stats_per_user"`
+
+	// Export bucket owners with radosgw_bucket_owner_info instead of an owner label
+	// on every bucket metric
+	BucketOwnerInfo bool `yaml:"bucket_owner_info"`
+
+	// Take users from the usage log and bucket owners instead of the admin API
+	LightMode bool `yaml:"light_mode"`
+
+	// Enabled sub-collectors, see subCollectors
+	Collectors []string `yaml:"collectors"`
+
+	// Parallel per-user admin API calls
+	Concurrency int `yaml:"rgw_concurrency"`
+
+	// Entries per page of user listings; listed in one request when 0. The usage
+	// and bucket endpoints take no paging parameters
+	PageSize int `yaml:"rgw_page_size"`
+
+	// Admin API requests per second across all collections; unlimited when 0
+	RGWRateLimit float64 `yaml:"rgw_rate_limit"`
+	RGWRateBurst int     `yaml:"rgw_rate_burst"`
+
+	// Hash partitioning of users between replicas; this replica handles shard ShardIndex of ShardTotal
+	ShardIndex int `yaml:"shard_index"`
+	ShardTotal int `yaml:"shard_total"`
+
+	// YAML file with /probe modules; /probe is disabled when empty
+	ConfigFile string `yaml:"config_file"`
+
+	// Targets /probe accepts besides the module's own endpoints, scheme://host[:port] with * wildcards in the host
+	ProbeTargets []string `yaml:"probe_targets"`
+
+	// Probed targets not scraped for this long have their collectors stopped
+	ProbeIdleTimeout time.Duration `yaml:"probe_idle_timeout"`
+
+	// Bearer token protecting the /config endpoint; the endpoint is disabled when empty
+	ConfigToken string `yaml:"config_token" secret:"true"`
+}
+
+// redactedValue — placeholder for masked secrets
+const redactedValue = "<redacted>"
+
+// sensitiveHeaders — extra request headers whose values are masked in config dumps
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// Redacted returns the configuration as ordered key/value pairs with secrets masked
+func (c Config) Redacted() yaml.MapSlice {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	out := make(yaml.MapSlice, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		var value any = v.Field(i).Interface()
+		switch typed := value.(type) {
+		case http.Header:
+			headers := make(map[string]string, len(typed))
+			for name := range typed {
+				headers[name] = typed.Get(name)
+				if slices.Contains(sensitiveHeaders, name) {
+					headers[name] = redactedValue
+				}
+			}
+			value = headers
+		case fmt.Stringer:
+			value = typed.String()
+		}
+		if field.Tag.Get("secret") == "true" && !v.Field(i).IsZero() {
+			value = redactedValue
+		}
+		out = append(out, yaml.MapItem{Key: field.Tag.Get("yaml"), Value: value})
+	}
+	return out
+}
+
+// legacyEnv maps namespaced variables to the deprecated names still accepted
+var legacyEnv = map[string]string{
+	"RADOSGW_EXPORTER_ENDPOINT":                     "RADOSGW_ENDPOINT",
+	"RADOSGW_EXPORTER_ACCESS_KEY":                   "ACCESS_KEY",
+	"RADOSGW_EXPORTER_SECRET_KEY":                   "SECRET_KEY",
+	"RADOSGW_EXPORTER_STORE":                        "STORE",
+	"RADOSGW_EXPORTER_METRICS_PORT":                 "METRICS_PORT",
+	"RADOSGW_EXPORTER_INSECURE_SKIP_VERIFY":         "INSECURE_SKIP_VERIFY",
+	"RADOSGW_EXPORTER_ADMIN_PATH":                   "RADOSGW_ADMIN_PATH",
+	"RADOSGW_EXPORTER_HTTP_USER_AGENT":              "HTTP_USER_AGENT",
+	"RADOSGW_EXPORTER_HTTP_HOST_HEADER":             "HTTP_HOST_HEADER",
+	"RADOSGW_EXPORTER_HTTP_HEADERS":                 "HTTP_HEADERS",
+	"RADOSGW_EXPORTER_HTTP_TIMEOUT":                 "HTTP_TIMEOUT",
+	"RADOSGW_EXPORTER_HTTP_DIAL_TIMEOUT":            "HTTP_DIAL_TIMEOUT",
+	"RADOSGW_EXPORTER_HTTP_KEEP_ALIVE":              "HTTP_KEEP_ALIVE",
+	"RADOSGW_EXPORTER_HTTP_MAX_IDLE_CONNS":          "HTTP_MAX_IDLE_CONNS",
+	"RADOSGW_EXPORTER_HTTP_MAX_IDLE_CONNS_PER_HOST": "HTTP_MAX_IDLE_CONNS_PER_HOST",
+	"RADOSGW_EXPORTER_HTTP_IDLE_CONN_TIMEOUT":       "HTTP_IDLE_CONN_TIMEOUT",
+}
+
+// lookupEnv returns a namespaced variable, falling back to its deprecated alias
+func lookupEnv(key string) string {
+	value := os.Getenv(key)
+	legacy, ok := legacyEnv[key]
+	if !ok {
+		return value
+	}
+	old := os.Getenv(legacy)
+	switch {
+	case old == "":
+		return value
+	case value != "":
+		slog.Warn("Both namespaced and deprecated variables are set, ignoring the deprecated one", "name", key, "deprecated", legacy)
+		return value
+	default:
+		slog.Warn("Deprecated environment variable, use the namespaced name instead", "deprecated", legacy, "name", key)
+		return old
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value := lookupEnv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getSecret reads a secret from the environment, falling back to a systemd
+// credential (LoadCredential=) in $CREDENTIALS_DIRECTORY
+func getSecret(key, credential string) string {
+	if value := lookupEnv(key); value != "" {
+		return value
+	}
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, credential))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := lookupEnv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	value := lookupEnv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// getEnvFloats parses a comma-separated list of numbers
+func getEnvFloats(key string, fallback []float64) ([]float64, error) {
+	values := splitList(lookupEnv(key))
+	if len(values) == 0 {
+		return fallback, nil
+	}
+	out := make([]float64, 0, len(values))
+	for _, value := range values {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value := lookupEnv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// tlsVersion — TLS protocol version in the TLS12/TLS13 notation
+type tlsVersion uint16
+
+var tlsVersions = map[string]tlsVersion{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+func parseTLSVersion(s string) (tlsVersion, error) {
+	if v, ok := tlsVersions[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("invalid RADOSGW_EXPORTER_RGW_TLS_MIN_VERSION %q, expected one of TLS10, TLS11, TLS12, TLS13", s)
+}
+
+// UnmarshalYAML accepts the same notation as RADOSGW_EXPORTER_RGW_TLS_MIN_VERSION
+func (v *tlsVersion) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := parseTLSVersion(s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+func (v tlsVersion) String() string {
+	for name, version := range tlsVersions {
+		if version == v {
+			return name
+		}
+	}
+	return ""
+}
+
+// cipherSuites — TLS 1.0-1.2 cipher suite IDs; TLS 1.3 suites are not configurable in Go
+type cipherSuites []uint16
+
+func parseCipherSuites(s string) (cipherSuites, error) {
+	var suites cipherSuites
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q in RADOSGW_EXPORTER_RGW_TLS_CIPHER_SUITES", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// UnmarshalYAML accepts the same comma-separated list as RADOSGW_EXPORTER_RGW_TLS_CIPHER_SUITES
+func (c *cipherSuites) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := parseCipherSuites(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+func (c cipherSuites) String() string {
+	names := make([]string, len(c))
+	for i, id := range c {
+		names[i] = tls.CipherSuiteName(id)
+	}
+	return strings.Join(names, ",")
+}
+
+// checkClientCertRequired verifies that the web config file makes the listener
+// require client certificates signed by its own CA
+func checkClientCertRequired(path string) error {
+	if path == "" {
+		return fmt.Errorf("RADOSGW_EXPORTER_WEB_REQUIRE_CLIENT_CERT needs RADOSGW_EXPORTER_WEB_CONFIG_FILE")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var webConfig web.Config
+	if err := yaml.Unmarshal(data, &webConfig); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	tlsConfig := webConfig.TLSConfig
+	if !tlsConfig.IsEnabled() {
+		return fmt.Errorf("%s: client certificates required but TLS is not enabled", path)
+	}
+	if tlsConfig.ClientAuth != "RequireAndVerifyClientCert" {
+		return fmt.Errorf("%s: client certificates required but client_auth_type is %q, expected RequireAndVerifyClientCert", path, tlsConfig.ClientAuth)
+	}
+	if tlsConfig.ClientCAs == "" && tlsConfig.ClientCAsText == "" {
+		return fmt.Errorf("%s: client certificates required but no client_ca_file is set", path)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseHeaders parses a comma-separated list of Name=value pairs
+func parseHeaders(s string) (http.Header, error) {
+	headers := http.Header{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid RADOSGW_EXPORTER_HTTP_HEADERS entry %q, expected Name=value", pair)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// loadConfig reads the configuration from environment variables
+func loadConfig() (Config, error) {
+	cfg := Config{
+		Endpoints: splitList(getEnv("RADOSGW_EXPORTER_ENDPOINT", "")),
+		AccessKey: getSecret("RADOSGW_EXPORTER_ACCESS_KEY", "access_key"),
+		SecretKey: getSecret("RADOSGW_EXPORTER_SECRET_KEY", "secret_key"),
+		Store:     getEnv("RADOSGW_EXPORTER_STORE", "us-east-1"),
+		Port:      getEnv("RADOSGW_EXPORTER_METRICS_PORT", "9242"),
+	}
+	// Further endpoints of the same cluster are failover targets
+	if len(cfg.Endpoints) > 0 {
+		cfg.Endpoint = cfg.Endpoints[0]
+	}
+	cfg.DiscoveryDNSName = getEnv("RADOSGW_EXPORTER_DISCOVERY_DNS_NAME", "")
+	cfg.DiscoveryK8sService = getEnv("RADOSGW_EXPORTER_DISCOVERY_KUBERNETES_SERVICE", "")
+	cfg.DiscoveryK8sPort = getEnv("RADOSGW_EXPORTER_DISCOVERY_KUBERNETES_PORT", "")
+	cfg.DiscoveryCephConf = getEnv("RADOSGW_EXPORTER_DISCOVERY_CEPH_CONF", "")
+	cfg.DiscoveryCephUser = getEnv("RADOSGW_EXPORTER_DISCOVERY_CEPH_USER", "admin")
+	cfg.DiscoveryCephKeyring = getEnv("RADOSGW_EXPORTER_DISCOVERY_CEPH_KEYRING", "")
+	cfg.DiscoveryCephZone = getEnv("RADOSGW_EXPORTER_DISCOVERY_CEPH_ZONE", "")
+
+	// The host of Endpoint is replaced by discovery on every request; only the scheme matters
+	discoveryHost := ""
+	sources := 0
+	for _, source := range []struct{ value, host string }{
+		{cfg.DiscoveryDNSName, cfg.DiscoveryDNSName},
+		{cfg.DiscoveryK8sService, k8sServiceHost(cfg.DiscoveryK8sService)},
+		{cfg.DiscoveryCephConf, "ceph-service-map"},
+	} {
+		if source.value != "" {
+			discoveryHost = source.host
+			sources++
+		}
+	}
+	if cfg.Endpoint == "" && sources > 0 {
+		cfg.Endpoint = getEnv("RADOSGW_EXPORTER_DISCOVERY_SCHEME", "https") + "://" + discoveryHost
+	}
+	if cfg.Endpoint == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return cfg, fmt.Errorf("required environment variables: RADOSGW_EXPORTER_ENDPOINT (or a discovery source), RADOSGW_EXPORTER_ACCESS_KEY, RADOSGW_EXPORTER_SECRET_KEY")
+	}
+	cfg.Insecure, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_INSECURE_SKIP_VERIFY", "false"))
+	cfg.StoreAutodetect, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_STORE_AUTODETECT", "false"))
+	cfg.AdminPath = strings.TrimRight(getEnv("RADOSGW_EXPORTER_ADMIN_PATH", defaultAdminPath), "/")
+	if cfg.AdminPath != "" && !strings.HasPrefix(cfg.AdminPath, "/") {
+		cfg.AdminPath = "/" + cfg.AdminPath
+	}
+
+	var err error
+	cfg.DiscoveryDNSType = strings.ToUpper(getEnv("RADOSGW_EXPORTER_DISCOVERY_DNS_TYPE", "SRV"))
+	defaultPort := "443"
+	if strings.HasPrefix(cfg.Endpoint, "http://") {
+		defaultPort = "80"
+	}
+	cfg.DiscoveryDNSPort = getEnv("RADOSGW_EXPORTER_DISCOVERY_DNS_PORT", defaultPort)
+	if cfg.DiscoveryInterval, err = getEnvDuration("RADOSGW_EXPORTER_DISCOVERY_INTERVAL", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.TLSMinVersion, err = parseTLSVersion(getEnv("RADOSGW_EXPORTER_RGW_TLS_MIN_VERSION", "TLS12")); err != nil {
+		return cfg, err
+	}
+	if cfg.TLSCipherSuites, err = parseCipherSuites(getEnv("RADOSGW_EXPORTER_RGW_TLS_CIPHER_SUITES", "")); err != nil {
+		return cfg, err
+	}
+
+	cfg.UserAgent = getEnv("RADOSGW_EXPORTER_HTTP_USER_AGENT", "radosgw_exporter")
+	cfg.HostHeader = getEnv("RADOSGW_EXPORTER_HTTP_HOST_HEADER", "")
+
+	if cfg.ExtraHeaders, err = parseHeaders(getEnv("RADOSGW_EXPORTER_HTTP_HEADERS", "")); err != nil {
+		return cfg, err
+	}
+	if cfg.HTTPTimeout, err = getEnvDuration("RADOSGW_EXPORTER_HTTP_TIMEOUT", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.HTTPDialTimeout, err = getEnvDuration("RADOSGW_EXPORTER_HTTP_DIAL_TIMEOUT", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.HTTPKeepAlive, err = getEnvDuration("RADOSGW_EXPORTER_HTTP_KEEP_ALIVE", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.HTTPMaxIdleConns, err = getEnvInt("RADOSGW_EXPORTER_HTTP_MAX_IDLE_CONNS", 100); err != nil {
+		return cfg, err
+	}
+	if cfg.HTTPMaxIdleConnsPerHost, err = getEnvInt("RADOSGW_EXPORTER_HTTP_MAX_IDLE_CONNS_PER_HOST", 10); err != nil {
+		return cfg, err
+	}
+	if cfg.HTTPIdleConnTimeout, err = getEnvDuration("RADOSGW_EXPORTER_HTTP_IDLE_CONN_TIMEOUT", 90*time.Second); err != nil {
+		return cfg, err
+	}
+
+	cfg.ListenAddresses = splitList(getEnv("RADOSGW_EXPORTER_WEB_LISTEN_ADDRESS", ":"+cfg.Port))
+	cfg.WebSystemdSocket, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_WEB_SYSTEMD_SOCKET", "false"))
+	cfg.TelemetryPath = getEnv("RADOSGW_EXPORTER_WEB_TELEMETRY_PATH", "/metrics")
+	cfg.WebConfigFile = getEnv("RADOSGW_EXPORTER_WEB_CONFIG_FILE", "")
+	cfg.WebRequireClientCert, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_WEB_REQUIRE_CLIENT_CERT", "false"))
+	if cfg.WebRequireClientCert {
+		if err := checkClientCertRequired(cfg.WebConfigFile); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.WebEnablePprof, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_WEB_ENABLE_PPROF", "false"))
+	cfg.WebAccessLog, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_WEB_ACCESS_LOG", "false"))
+	cfg.HealthListenAddress = getEnv("RADOSGW_EXPORTER_WEB_HEALTH_LISTEN_ADDRESS", "")
+	if cfg.WebMaxRequests, err = getEnvInt("RADOSGW_EXPORTER_WEB_MAX_REQUESTS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.WebTimeout, err = getEnvDuration("RADOSGW_EXPORTER_WEB_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	cfg.WebErrorHandling = getEnv("RADOSGW_EXPORTER_WEB_ERROR_HANDLING", "http")
+	cfg.WebCompression = getEnv("RADOSGW_EXPORTER_WEB_COMPRESSION", "auto")
+	if cfg.WebRateLimit, err = getEnvFloat("RADOSGW_EXPORTER_WEB_RATE_LIMIT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.WebRateBurst, err = getEnvInt("RADOSGW_EXPORTER_WEB_RATE_BURST", 1); err != nil {
+		return cfg, err
+	}
+	cfg.WebCORSOrigins = splitList(getEnv("RADOSGW_EXPORTER_WEB_CORS_ORIGINS", ""))
+	cfg.WebCORSMethods = splitList(getEnv("RADOSGW_EXPORTER_WEB_CORS_METHODS", "GET,OPTIONS"))
+	if cfg.ScrapeInterval, err = getEnvDuration("RADOSGW_EXPORTER_SCRAPE_INTERVAL", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.SlowScrapeWarning, err = getEnvDuration("RADOSGW_EXPORTER_SLOW_SCRAPE_WARNING", 10*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.ScrapeDurationBuckets, err = getEnvFloats("RADOSGW_EXPORTER_SCRAPE_DURATION_BUCKETS", []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}); err != nil {
+		return cfg, err
+	}
+	cfg.SerializeScrapes, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_SERIALIZE_SCRAPES", "false"))
+	cfg.CacheFile = getEnv("RADOSGW_EXPORTER_CACHE_FILE", "")
+	if cfg.ScrapeTimeout, err = getEnvDuration("RADOSGW_EXPORTER_SCRAPE_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.WarmupTimeout, err = getEnvDuration("RADOSGW_EXPORTER_WARMUP_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.DownAfterFailures, err = getEnvInt("RADOSGW_EXPORTER_DOWN_AFTER_FAILURES", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.BreakerFailures, err = getEnvInt("RADOSGW_EXPORTER_BREAKER_FAILURES", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.BreakerCooldown, err = getEnvDuration("RADOSGW_EXPORTER_BREAKER_COOLDOWN", time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.UserDetailsEvery, err = getEnvInt("RADOSGW_EXPORTER_USER_DETAILS_EVERY", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.SeriesLimit, err = getEnvInt("RADOSGW_EXPORTER_SERIES_LIMIT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxUsers, err = getEnvInt("RADOSGW_EXPORTER_LIMIT_MAX_USERS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxBuckets, err = getEnvInt("RADOSGW_EXPORTER_LIMIT_MAX_BUCKETS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.UserFailureTTL, err = getEnvDuration("RADOSGW_EXPORTER_USER_FAILURE_TTL", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.StaleMaxAge, err = getEnvDuration("RADOSGW_EXPORTER_STALE_MAX_AGE", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.ScrapeTimeoutOffset, err = getEnvDuration("RADOSGW_EXPORTER_SCRAPE_TIMEOUT_OFFSET", 500*time.Millisecond); err != nil {
+		return cfg, err
+	}
+	cfg.LeaderElection = getEnv("RADOSGW_EXPORTER_LEADER_ELECTION", "")
+	cfg.LeaderIdentity = getEnv("RADOSGW_EXPORTER_LEADER_IDENTITY", os.Getenv("POD_NAME"))
+	cfg.LeaderLeaseName = getEnv("RADOSGW_EXPORTER_LEADER_LEASE_NAME", "radosgw-exporter")
+	cfg.LeaderLeaseNamespace = getEnv("RADOSGW_EXPORTER_LEADER_LEASE_NAMESPACE", "")
+	if cfg.LeaderLeaseDuration, err = getEnvDuration("RADOSGW_EXPORTER_LEADER_LEASE_DURATION", 15*time.Second); err != nil {
+		return cfg, err
+	}
+	cfg.LeaderLockFile = getEnv("RADOSGW_EXPORTER_LEADER_LOCK_FILE", "")
+	cfg.UsageIncremental, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_USAGE_INCREMENTAL", "false"))
+	cfg.UsageResetCorrection, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_USAGE_RESET_CORRECTION", "false"))
+	cfg.UsageRates, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_USAGE_RATES", "false"))
+	if cfg.SeriesTTL, err = getEnvInt("RADOSGW_EXPORTER_SERIES_TTL", 0); err != nil {
+		return cfg, err
+	}
+	cfg.BucketStatsPerUser, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_BUCKET_STATS_PER_USER", "false"))
+	cfg.BucketOwnerInfo, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_BUCKET_OWNER_INFO", "false"))
+	cfg.LightMode, _ = strconv.ParseBool(getEnv("RADOSGW_EXPORTER_LIGHT_MODE", "false"))
+	for _, sc := range subCollectors {
+		enabled, _ := strconv.ParseBool(getEnv("RADOSGW_EXPORTER_COLLECTOR_"+strings.ToUpper(sc.name), strconv.FormatBool(!sc.off)))
+		if enabled {
+			cfg.Collectors = append(cfg.Collectors, sc.name)
+		}
+	}
+	if cfg.Concurrency, err = getEnvInt("RADOSGW_EXPORTER_RGW_CONCURRENCY", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.PageSize, err = getEnvInt("RADOSGW_EXPORTER_RGW_PAGE_SIZE", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.RGWRateLimit, err = getEnvFloat("RADOSGW_EXPORTER_RGW_RATE_LIMIT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.RGWRateBurst, err = getEnvInt("RADOSGW_EXPORTER_RGW_RATE_BURST", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.ShardIndex, err = getEnvInt("RADOSGW_EXPORTER_SHARD_INDEX", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.ShardTotal, err = getEnvInt("RADOSGW_EXPORTER_SHARD_TOTAL", 1); err != nil {
+		return cfg, err
+	}
+	cfg.ConfigFile = getEnv("RADOSGW_EXPORTER_CONFIG_FILE", "")
+	cfg.ProbeTargets = splitList(getEnv("RADOSGW_EXPORTER_PROBE_TARGETS", ""))
+	if cfg.ProbeIdleTimeout, err = getEnvDuration("RADOSGW_EXPORTER_PROBE_IDLE_TIMEOUT", 15*time.Minute); err != nil {
+		return cfg, err
+	}
+	cfg.ConfigToken = getEnv("RADOSGW_EXPORTER_CONFIG_TOKEN", "")
+
+	return cfg, cfg.validate()
+}
+
+// validate checks the settings read by loadConfig or overridden by a probe module
+func (c Config) validate() error {
+	for _, endpoint := range c.Endpoints {
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid endpoint %q in RADOSGW_EXPORTER_ENDPOINT, expected scheme://host[:port]", endpoint)
+		}
+	}
+	sources := 0
+	for _, source := range []string{c.DiscoveryDNSName, c.DiscoveryK8sService, c.DiscoveryCephConf} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("RADOSGW_EXPORTER_DISCOVERY_DNS_NAME, RADOSGW_EXPORTER_DISCOVERY_KUBERNETES_SERVICE and RADOSGW_EXPORTER_DISCOVERY_CEPH_CONF are mutually exclusive")
+	}
+	if len(c.Endpoints) > 1 && sources > 0 {
+		return fmt.Errorf("several endpoints in RADOSGW_EXPORTER_ENDPOINT cannot be combined with gateway discovery")
+	}
+	if c.DiscoveryDNSType != "SRV" && c.DiscoveryDNSType != "A" {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_DISCOVERY_DNS_TYPE %q, expected SRV or A", c.DiscoveryDNSType)
+	}
+	if c.DiscoveryInterval <= 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_DISCOVERY_INTERVAL %s, expected a positive duration", c.DiscoveryInterval)
+	}
+	if !strings.HasPrefix(c.TelemetryPath, "/") || c.TelemetryPath == "/" {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_WEB_TELEMETRY_PATH %q, expected an absolute path other than /", c.TelemetryPath)
+	}
+	if _, ok := errorHandling[c.WebErrorHandling]; !ok {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_WEB_ERROR_HANDLING %q, expected http, continue or panic", c.WebErrorHandling)
+	}
+	if !slices.Contains(compressionModes, c.WebCompression) {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_WEB_COMPRESSION %q, expected auto, gzip or none", c.WebCompression)
+	}
+	if c.WebRateLimit < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_WEB_RATE_LIMIT %g, expected a positive rate or 0", c.WebRateLimit)
+	}
+	if c.WebRateLimit > 0 && c.WebRateBurst < 1 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_WEB_RATE_BURST %d, expected at least 1", c.WebRateBurst)
+	}
+	if c.ScrapeInterval < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SCRAPE_INTERVAL %s, expected a positive duration or 0", c.ScrapeInterval)
+	}
+	if c.SlowScrapeWarning < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SLOW_SCRAPE_WARNING %s, expected a positive duration or 0", c.SlowScrapeWarning)
+	}
+	for i := 1; i < len(c.ScrapeDurationBuckets); i++ {
+		if c.ScrapeDurationBuckets[i] <= c.ScrapeDurationBuckets[i-1] {
+			return fmt.Errorf("invalid RADOSGW_EXPORTER_SCRAPE_DURATION_BUCKETS %v, expected increasing numbers", c.ScrapeDurationBuckets)
+		}
+	}
+	if c.CacheFile != "" && c.ScrapeInterval == 0 {
+		return fmt.Errorf("RADOSGW_EXPORTER_CACHE_FILE requires RADOSGW_EXPORTER_SCRAPE_INTERVAL")
+	}
+	if c.ScrapeTimeout < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SCRAPE_TIMEOUT %s, expected a positive duration or 0", c.ScrapeTimeout)
+	}
+	if c.WarmupTimeout < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_WARMUP_TIMEOUT %s, expected a positive duration or 0", c.WarmupTimeout)
+	}
+	if c.DownAfterFailures < 1 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_DOWN_AFTER_FAILURES %d, expected a positive number", c.DownAfterFailures)
+	}
+	if c.BreakerFailures < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_BREAKER_FAILURES %d, expected a positive number or 0", c.BreakerFailures)
+	}
+	if c.UserDetailsEvery < 1 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_USER_DETAILS_EVERY %d, expected at least 1", c.UserDetailsEvery)
+	}
+	if c.SeriesLimit < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SERIES_LIMIT %d, expected a positive number or 0", c.SeriesLimit)
+	}
+	if c.MaxUsers < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_LIMIT_MAX_USERS %d, expected a positive number or 0", c.MaxUsers)
+	}
+	if c.MaxBuckets < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_LIMIT_MAX_BUCKETS %d, expected a positive number or 0", c.MaxBuckets)
+	}
+	if c.UserFailureTTL < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_USER_FAILURE_TTL %s, expected a positive duration or 0", c.UserFailureTTL)
+	}
+	if c.StaleMaxAge < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_STALE_MAX_AGE %s, expected a positive duration or 0", c.StaleMaxAge)
+	}
+	switch {
+	case c.LeaderElection != "" && c.LeaderElection != "kubernetes" && c.LeaderElection != "file":
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_LEADER_ELECTION %q, expected kubernetes or file", c.LeaderElection)
+	case c.LeaderElection == "file" && c.LeaderLockFile == "":
+		return fmt.Errorf("RADOSGW_EXPORTER_LEADER_ELECTION=file requires RADOSGW_EXPORTER_LEADER_LOCK_FILE")
+	case c.LeaderElection != "" && c.LeaderLeaseDuration < 3*time.Second:
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_LEADER_LEASE_DURATION %s, expected at least 3s", c.LeaderLeaseDuration)
+	}
+	if c.UsageRates && c.ScrapeInterval == 0 {
+		return fmt.Errorf("RADOSGW_EXPORTER_USAGE_RATES requires RADOSGW_EXPORTER_SCRAPE_INTERVAL")
+	}
+	if c.SeriesTTL < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SERIES_TTL %d, expected a positive number or 0", c.SeriesTTL)
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_RGW_CONCURRENCY %d, expected at least 1", c.Concurrency)
+	}
+	if c.PageSize < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_RGW_PAGE_SIZE %d, expected a positive number or 0", c.PageSize)
+	}
+	if c.RGWRateLimit < 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_RGW_RATE_LIMIT %g, expected a positive rate or 0", c.RGWRateLimit)
+	}
+	if c.RGWRateLimit > 0 && c.RGWRateBurst < 1 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_RGW_RATE_BURST %d, expected at least 1", c.RGWRateBurst)
+	}
+	if c.ShardTotal < 1 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SHARD_TOTAL %d, expected at least 1", c.ShardTotal)
+	}
+	if c.ShardIndex < 0 || c.ShardIndex >= c.ShardTotal {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_SHARD_INDEX %d, expected 0 to %d", c.ShardIndex, c.ShardTotal-1)
+	}
+	for _, target := range c.ProbeTargets {
+		u, err := url.Parse(target)
+		if _, matchErr := path.Match(target, ""); err != nil || matchErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid target %q in RADOSGW_EXPORTER_PROBE_TARGETS, expected http(s)://host[:port]", target)
+		}
+	}
+	if c.ProbeIdleTimeout <= 0 {
+		return fmt.Errorf("invalid RADOSGW_EXPORTER_PROBE_IDLE_TIMEOUT %s, expected a positive duration", c.ProbeIdleTimeout)
+	}
+	return nil
+}
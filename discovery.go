@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gatewayDiscovery tracks the RGW gateways published by a discovery source and
+// picks a reachable one for admin API calls
+type gatewayDiscovery struct {
+	// source names what is being discovered, for logs
+	source string
+	lookup func(ctx context.Context) ([]string, error)
+	// host is sent as Host and verified as TLS server name when candidates are
+	// bare IPs; when empty the candidate itself is used
+	host string
+
+	interval    time.Duration
+	dialTimeout time.Duration
+	logger      *slog.Logger
+
+	mu      sync.RWMutex
+	current string
+}
+
+// newDiscovery returns the discovery configured in cfg, or nil when the static
+// endpoint is used
+func newDiscovery(cfg Config, logger *slog.Logger) (*gatewayDiscovery, error) {
+	d := &gatewayDiscovery{
+		interval:    cfg.DiscoveryInterval,
+		dialTimeout: cfg.HTTPDialTimeout,
+		logger:      logger,
+	}
+	switch {
+	case cfg.DiscoveryDNSName != "":
+		d.source = cfg.DiscoveryDNSName
+		d.lookup = dnsLookup(net.DefaultResolver, cfg.DiscoveryDNSType, cfg.DiscoveryDNSName, cfg.DiscoveryDNSPort)
+		if cfg.DiscoveryDNSType != "SRV" {
+			d.host = net.JoinHostPort(cfg.DiscoveryDNSName, cfg.DiscoveryDNSPort)
+		}
+	case cfg.DiscoveryK8sService != "":
+		k8s, err := newK8sEndpoints(cfg.DiscoveryK8sService, cfg.DiscoveryK8sPort)
+		if err != nil {
+			return nil, err
+		}
+		d.source = k8s.namespace + "/" + k8s.service
+		d.lookup = k8s.lookup
+		d.host = k8s.service + "." + k8s.namespace + ".svc"
+	case cfg.DiscoveryCephConf != "":
+		lookup, err := cephServiceMapLookup(cfg)
+		if err != nil {
+			return nil, err
+		}
+		d.source = "Ceph service map"
+		d.lookup = lookup
+	default:
+		return nil, nil
+	}
+	return d, nil
+}
+
+// endpoint returns the host:port of the gateway currently in use, empty before
+// the first successful lookup
+func (d *gatewayDiscovery) endpoint() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.current
+}
+
+// hostHeader returns the Host to send to the gateway at endpoint
+func (d *gatewayDiscovery) hostHeader(endpoint string) string {
+	if d.host == "" {
+		return endpoint
+	}
+	if _, port, err := net.SplitHostPort(endpoint); err == nil && !strings.Contains(d.host, ":") {
+		return net.JoinHostPort(d.host, port)
+	}
+	return d.host
+}
+
+// serverName returns the name to verify gateway certificates against, empty to
+// use the candidate host
+func (d *gatewayDiscovery) serverName() string {
+	if host, _, err := net.SplitHostPort(d.host); err == nil {
+		return host
+	}
+	return d.host
+}
+
+// run repeats the lookup every interval until ctx is done
+func (d *gatewayDiscovery) run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.refresh(ctx); err != nil {
+				d.logger.Warn("Gateway discovery failed, keeping current endpoint", "source", d.source, "endpoint", d.endpoint(), "error", err)
+			}
+		}
+	}
+}
+
+// refresh looks the gateways up and switches to a reachable one, preferring
+// the current endpoint while it is still published and reachable
+func (d *gatewayDiscovery) refresh(ctx context.Context) error {
+	candidates, err := d.lookup(ctx)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no gateways published by %s", d.source)
+	}
+
+	current := d.endpoint()
+	if i := slices.Index(candidates, current); i > 0 {
+		candidates = append([]string{current}, slices.Delete(candidates, i, i+1)...)
+	}
+
+	next := ""
+	for _, candidate := range candidates {
+		if d.reachable(ctx, candidate) {
+			next = candidate
+			break
+		}
+	}
+	if next == "" {
+		return fmt.Errorf("none of %d gateways published by %s is reachable", len(candidates), d.source)
+	}
+	if next != current {
+		d.logger.Info("Gateway discovery selected RGW endpoint", "source", d.source, "endpoint", next, "candidates", len(candidates))
+		d.mu.Lock()
+		d.current = next
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// reachable reports whether a TCP connection to endpoint can be opened
+func (d *gatewayDiscovery) reachable(ctx context.Context, endpoint string) bool {
+	dialer := net.Dialer{Timeout: d.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		d.logger.Debug("RGW endpoint unreachable", "endpoint", endpoint, "error", err)
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// dnsLookup returns host:port candidates in SRV priority/weight order, or the
+// addresses of name with port for address records
+func dnsLookup(resolver *net.Resolver, recordType, name, port string) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		if recordType == "SRV" {
+			_, records, err := resolver.LookupSRV(ctx, "", "", name)
+			if err != nil {
+				return nil, err
+			}
+			out := make([]string, 0, len(records))
+			for _, srv := range records {
+				out = append(out, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+			}
+			return out, nil
+		}
+
+		addrs, err := resolver.LookupHost(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			out = append(out, net.JoinHostPort(addr, port))
+		}
+		return out, nil
+	}
+}
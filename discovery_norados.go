@@ -0,0 +1,13 @@
+//go:build !rados
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// cephServiceMapLookup is unavailable without librados; build with -tags rados
+func cephServiceMapLookup(Config) (func(ctx context.Context) ([]string, error), error) {
+	return nil, errors.New("this build has no librados support, rebuild with CGO_ENABLED=1 and -tags rados")
+}
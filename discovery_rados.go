@@ -0,0 +1,41 @@
+//go:build rados
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+// cephServiceMapLookup lists RGW daemons from the service map of the Ceph cluster
+// described by cfg, connecting with librados on every lookup
+func cephServiceMapLookup(cfg Config) (func(ctx context.Context) ([]string, error), error) {
+	tls := strings.HasPrefix(cfg.Endpoint, "https://")
+	return func(ctx context.Context) ([]string, error) {
+		conn, err := rados.NewConnWithUser(cfg.DiscoveryCephUser)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Shutdown()
+		if err := conn.ReadConfigFile(cfg.DiscoveryCephConf); err != nil {
+			return nil, fmt.Errorf("read %s: %w", cfg.DiscoveryCephConf, err)
+		}
+		if cfg.DiscoveryCephKeyring != "" {
+			if err := conn.SetConfigOption("keyring", cfg.DiscoveryCephKeyring); err != nil {
+				return nil, err
+			}
+		}
+		if err := conn.Connect(); err != nil {
+			return nil, fmt.Errorf("connect to Ceph cluster: %w", err)
+		}
+
+		out, status, err := conn.MgrCommand([][]byte{[]byte(`{"prefix":"service dump","format":"json"}`)})
+		if err != nil {
+			return nil, fmt.Errorf("service dump: %w: %s", err, status)
+		}
+		return parseServiceMap(out, tls, cfg.DiscoveryCephZone)
+	}, nil
+}
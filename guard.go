@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// otherLabel — label value of the series the buckets and users beyond the series
+// limit are summed into
+const otherLabel = "other"
+
+// seriesGuard caps the series one sub-collector exports individually in a
+// collection; the buckets, users or usage beyond the cap are summed into series
+// labelled "other". A nil guard lets everything through
+type seriesGuard struct {
+	left    atomic.Int64
+	dropped *atomic.Uint64
+
+	mu     sync.Mutex
+	folded bool
+	sizes  bucketTotals
+	usage  usageAggregate
+}
+
+func newSeriesGuard(limit int, dropped *atomic.Uint64) *seriesGuard {
+	g := &seriesGuard{dropped: dropped}
+	g.left.Store(int64(limit))
+	return g
+}
+
+// allow takes n series from the budget; once it is spent they are counted as
+// dropped and the caller folds them instead
+func (g *seriesGuard) allow(n int) bool {
+	if g == nil || g.left.Add(-int64(n)) >= 0 {
+		return true
+	}
+	g.dropped.Add(uint64(n))
+	return false
+}
+
+// foldSizes adds a size, a size after compression and an object count to the
+// other series
+func (g *seriesGuard) foldSizes(bytes, utilized, objects *uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.folded = true
+	if bytes != nil {
+		g.sizes.bytes += *bytes
+	}
+	if utilized != nil {
+		g.sizes.utilized += *utilized
+	}
+	if objects != nil {
+		g.sizes.objects += *objects
+	}
+}
+
+// foldUsage adds the usage of key to the other series of its category
+func (g *seriesGuard) foldUsage(key usageMetricKey, v usageMetricValues) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.folded = true
+	if g.usage == nil {
+		g.usage = make(usageAggregate)
+	}
+	key.bucket, key.owner = otherLabel, otherLabel
+	g.usage.add(key, v)
+}
+
+// other returns what has been folded, if anything
+func (g *seriesGuard) other() (sizes bucketTotals, usage usageAggregate, ok bool) {
+	if g == nil {
+		return bucketTotals{}, nil, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sizes, g.usage, g.folded
+}
+
+// seriesExpiry remembers in which successful collection every bucket and user was
+// last reported by RGW, so that what the exporter keeps about deleted ones (the
+// settled usage totals, reset corrections and rates) expires after ttl collections
+type seriesExpiry struct {
+	ttl int
+
+	mu sync.Mutex
+	// Successful collections so far; observations are stamped with it
+	round   int
+	buckets map[[2]string]int
+	users   map[string]int
+}
+
+func newSeriesExpiry(ttl int) *seriesExpiry {
+	return &seriesExpiry{ttl: ttl, buckets: make(map[[2]string]int), users: make(map[string]int)}
+}
+
+// observeBucket records that RGW reported bucket of owner
+func (e *seriesExpiry) observeBucket(owner, bucket string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.buckets[[2]string{owner, bucket}] = e.round
+	e.users[owner] = e.round
+}
+
+// observeUser records that RGW reported uid
+func (e *seriesExpiry) observeUser(uid string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.users[uid] = e.round
+}
+
+// expired reports whether the bucket of key, or its owner for usage outside
+// buckets, has not been reported in the last ttl successful collections; the
+// other series never expire
+func (e *seriesExpiry) expired(key usageMetricKey) bool {
+	if key.owner == otherLabel {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	round, ok := e.users[key.owner]
+	if key.bucket != "bucket_root" {
+		round, ok = e.buckets[[2]string{key.owner, key.bucket}]
+	}
+	return !ok || e.round-round > e.ttl
+}
+
+// done ends a successful collection and forgets what has expired
+func (e *seriesExpiry) done() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.round++
+	for key, round := range e.buckets {
+		if e.round-round > e.ttl {
+			delete(e.buckets, key)
+		}
+	}
+	for uid, round := range e.users {
+		if e.round-round > e.ttl {
+			delete(e.users, uid)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir — where Kubernetes mounts the pod's service account credentials
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sClient talks to the API server of the cluster the exporter runs in
+type k8sClient struct {
+	apiServer string
+	// namespace of the exporter's own pod
+	namespace string
+	client    *http.Client
+}
+
+// k8sStatusError — non-2xx answer of the API server
+type k8sStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *k8sStatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.code, http.StatusText(e.code), e.msg)
+}
+
+// isK8sStatus reports whether err is an API server answer with the given status code
+func isK8sStatus(err error, code int) bool {
+	var status *k8sStatusError
+	return errors.As(err, &status) && status.code == code
+}
+
+func newK8sClient() (*k8sClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("Kubernetes integration requires running in-cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates in cluster CA bundle")
+	}
+	namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("read pod namespace: %w", err)
+	}
+
+	return &k8sClient{
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		namespace: strings.TrimSpace(string(namespace)),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+// do sends a JSON request to path and decodes the JSON answer into out, if not nil
+func (k *k8sClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.apiServer+path, body)
+	if err != nil {
+		return err
+	}
+
+	// The token is rotated by the kubelet, so read it on every call
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return fmt.Errorf("read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &k8sStatusError{code: resp.StatusCode, msg: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// k8sEndpoints lists the ready gateway pods behind a Service through the
+// EndpointSlice API
+type k8sEndpoints struct {
+	api       *k8sClient
+	namespace string
+	service   string
+	port      string
+}
+
+// endpointSliceList — the fields of discovery.k8s.io/v1 EndpointSliceList we read
+type endpointSliceList struct {
+	Items []struct {
+		Endpoints []struct {
+			Addresses  []string `json:"addresses"`
+			Conditions struct {
+				Ready *bool `json:"ready"`
+			} `json:"conditions"`
+		} `json:"endpoints"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port *int   `json:"port"`
+		} `json:"ports"`
+	} `json:"items"`
+}
+
+// newK8sEndpoints configures the lookup of service ("name" or "namespace/name");
+// port selects a named Service port, the first one is used when empty
+func newK8sEndpoints(service, port string) (*k8sEndpoints, error) {
+	api, err := newK8sClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace, name, ok := strings.Cut(service, "/")
+	if !ok {
+		namespace, name = api.namespace, service
+	}
+	return &k8sEndpoints{api: api, namespace: namespace, service: name, port: port}, nil
+}
+
+// k8sServiceHost returns the DNS name of service ("name" or "namespace/name"),
+// name.namespace as the cluster DNS resolves it
+func k8sServiceHost(service string) string {
+	if namespace, name, ok := strings.Cut(service, "/"); ok {
+		return name + "." + namespace
+	}
+	return service
+}
+
+// lookup returns ip:port of every ready endpoint of the service
+func (k *k8sEndpoints) lookup(ctx context.Context) ([]string, error) {
+	query := url.Values{"labelSelector": {"kubernetes.io/service-name=" + k.service}}
+	var list endpointSliceList
+	err := k.api.do(ctx, http.MethodGet, "/apis/discovery.k8s.io/v1/namespaces/"+url.PathEscape(k.namespace)+"/endpointslices?"+query.Encode(), nil, &list)
+	if err != nil {
+		return nil, fmt.Errorf("list EndpointSlices of %s/%s: %w", k.namespace, k.service, err)
+	}
+
+	var out []string
+	for _, slice := range list.Items {
+		port := ""
+		for _, p := range slice.Ports {
+			if p.Port != nil && (k.port == "" || p.Name == k.port) {
+				port = strconv.Itoa(*p.Port)
+				break
+			}
+		}
+		if port == "" {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			// A missing condition means ready, per the EndpointSlice API
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, addr := range endpoint.Addresses {
+				out = append(out, net.JoinHostPort(addr, port))
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// leaderElector decides which of several exporter replicas collects from RGW
+type leaderElector struct {
+	identity string
+	period   time.Duration
+	logger   *slog.Logger
+	// try attempts to acquire or renew leadership and reports whether it is held
+	try func(ctx context.Context) (bool, error)
+	// release gives leadership up on shutdown
+	release func(ctx context.Context)
+
+	leader atomic.Bool
+}
+
+// newLeaderElector returns the elector configured in cfg, or nil when every
+// replica collects
+func newLeaderElector(cfg Config, logger *slog.Logger) (*leaderElector, error) {
+	identity := cfg.LeaderIdentity
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+	e := &leaderElector{
+		identity: identity,
+		period:   cfg.LeaderLeaseDuration / 3,
+		logger:   logger,
+	}
+
+	switch cfg.LeaderElection {
+	case "":
+		return nil, nil
+	case "kubernetes":
+		lease, err := newK8sLease(cfg, identity)
+		if err != nil {
+			return nil, err
+		}
+		e.try, e.release = lease.try, lease.release
+	case "file":
+		lock := &fileLock{path: cfg.LeaderLockFile}
+		e.try, e.release = lock.try, lock.release
+	default:
+		return nil, fmt.Errorf("unknown leader election mode %q", cfg.LeaderElection)
+	}
+	return e, nil
+}
+
+// IsLeader reports whether this replica currently holds leadership
+func (e *leaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// run keeps trying to acquire or renew leadership until ctx is done, then releases it
+func (e *leaderElector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.period)
+	defer ticker.Stop()
+	for {
+		leader, err := e.try(ctx)
+		if err != nil {
+			e.logger.Warn("Leader election attempt failed", "identity", e.identity, "error", err)
+		}
+		if leader != e.leader.Load() {
+			e.leader.Store(leader)
+			e.logger.Info("Leadership changed", "identity", e.identity, "leader", leader)
+		}
+
+		select {
+		case <-ctx.Done():
+			if e.leader.Load() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), e.period)
+				e.release(releaseCtx)
+				cancel()
+				e.leader.Store(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// k8sLease implements leader election on a coordination.k8s.io/v1 Lease
+type k8sLease struct {
+	api      *k8sClient
+	path     string
+	name     string
+	identity string
+	duration time.Duration
+
+	// Time of the last successful renewal; leadership is assumed lost once it is
+	// older than the lease duration
+	renewed time.Time
+}
+
+// lease — the fields of a coordination.k8s.io/v1 Lease we read and write
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace,omitempty"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       *string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds *int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          *string `json:"acquireTime,omitempty"`
+		RenewTime            *string `json:"renewTime,omitempty"`
+		LeaseTransitions     *int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// microTime — layout of metav1.MicroTime
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+func newK8sLease(cfg Config, identity string) (*k8sLease, error) {
+	api, err := newK8sClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace := cfg.LeaderLeaseNamespace
+	if namespace == "" {
+		namespace = api.namespace
+	}
+	return &k8sLease{
+		api:      api,
+		path:     "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(namespace) + "/leases",
+		name:     cfg.LeaderLeaseName,
+		identity: identity,
+		duration: cfg.LeaderLeaseDuration,
+	}, nil
+}
+
+// try creates the Lease, renews it, or takes it over once its holder let it expire
+func (l *k8sLease) try(ctx context.Context) (bool, error) {
+	now := time.Now()
+	var current lease
+	err := l.api.do(ctx, http.MethodGet, l.path+"/"+url.PathEscape(l.name), nil, &current)
+	switch {
+	case isK8sStatus(err, http.StatusNotFound):
+		current = lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		current.Metadata.Name = l.name
+		l.hold(&current, now, true)
+		if err := l.api.do(ctx, http.MethodPost, l.path, current, nil); err != nil {
+			return false, fmt.Errorf("create lease %s: %w", l.name, err)
+		}
+		l.renewed = now
+		return true, nil
+	case err != nil:
+		return l.stillHeld(now), fmt.Errorf("get lease %s: %w", l.name, err)
+	}
+
+	holder := ""
+	if current.Spec.HolderIdentity != nil {
+		holder = *current.Spec.HolderIdentity
+	}
+	if holder != l.identity && holder != "" && !leaseExpired(current, now) {
+		l.renewed = time.Time{}
+		return false, nil
+	}
+
+	l.hold(&current, now, holder != l.identity)
+	if err := l.api.do(ctx, http.MethodPut, l.path+"/"+url.PathEscape(l.name), current, nil); err != nil {
+		// A conflict means another replica updated the Lease first
+		if isK8sStatus(err, http.StatusConflict) {
+			l.renewed = time.Time{}
+			return false, nil
+		}
+		return l.stillHeld(now), fmt.Errorf("update lease %s: %w", l.name, err)
+	}
+	l.renewed = now
+	return true, nil
+}
+
+// hold stamps l's identity and renewal time on the Lease
+func (l *k8sLease) hold(current *lease, now time.Time, acquire bool) {
+	stamp := now.UTC().Format(microTime)
+	seconds := int(l.duration.Seconds())
+	current.Spec.HolderIdentity = &l.identity
+	current.Spec.LeaseDurationSeconds = &seconds
+	current.Spec.RenewTime = &stamp
+	if acquire {
+		current.Spec.AcquireTime = &stamp
+		transitions := 0
+		if current.Spec.LeaseTransitions != nil {
+			transitions = *current.Spec.LeaseTransitions + 1
+		}
+		current.Spec.LeaseTransitions = &transitions
+	}
+}
+
+// stillHeld keeps leadership across API errors until the lease could have expired
+func (l *k8sLease) stillHeld(now time.Time) bool {
+	return !l.renewed.IsZero() && now.Sub(l.renewed) < l.duration
+}
+
+// release clears the holder so a standby can take over without waiting for expiry
+func (l *k8sLease) release(ctx context.Context) {
+	var current lease
+	if err := l.api.do(ctx, http.MethodGet, l.path+"/"+url.PathEscape(l.name), nil, &current); err != nil {
+		return
+	}
+	if current.Spec.HolderIdentity == nil || *current.Spec.HolderIdentity != l.identity {
+		return
+	}
+	empty := ""
+	current.Spec.HolderIdentity = &empty
+	l.api.do(ctx, http.MethodPut, l.path+"/"+url.PathEscape(l.name), current, nil)
+}
+
+// leaseExpired reports whether the Lease holder has not renewed it in time
+func leaseExpired(current lease, now time.Time) bool {
+	if current.Spec.RenewTime == nil || current.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	renewed, err := time.Parse(microTime, *current.Spec.RenewTime)
+	if err != nil {
+		renewed, err = time.Parse(time.RFC3339Nano, *current.Spec.RenewTime)
+		if err != nil {
+			return true
+		}
+	}
+	return now.After(renewed.Add(time.Duration(*current.Spec.LeaseDurationSeconds) * time.Second))
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// fileLock is not supported on this platform
+type fileLock struct {
+	path string
+}
+
+func (l *fileLock) try(context.Context) (bool, error) {
+	return false, errors.New("file lock leader election is only supported on Unix")
+}
+
+func (l *fileLock) release(context.Context) {}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fileLock implements leader election with an exclusive flock(2) on a shared file
+type fileLock struct {
+	path string
+	file *os.File
+}
+
+// try takes the lock without blocking; once taken it is held until release
+func (l *fileLock) try(context.Context) (bool, error) {
+	if l.file != nil {
+		return true, nil
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, err
+	}
+	l.file = f
+	return true, nil
+}
+
+func (l *fileLock) release(context.Context) {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
@@ -2,26 +2,29 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+	"go.yaml.in/yaml/v2"
 )
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func main() {
+	printConfig := flag.Bool("print-config", false, "Print the resolved configuration with secrets masked and exit")
+	printVersion := flag.Bool("version", false, "Print version information and exit")
+	flag.Parse()
+	if *printVersion {
+		fmt.Println(currentBuildInfo())
+		return
 	}
-	return fallback
-}
 
-func main() {
 	// Configure logger
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -29,45 +32,179 @@ func main() {
 	slog.SetDefault(logger)
 
 	// Load configuration from environment
-	endpoint := getEnv("RADOSGW_ENDPOINT", "")
-	accessKey := getEnv("ACCESS_KEY", "")
-	secretKey := getEnv("SECRET_KEY", "")
-	if endpoint == "" || accessKey == "" || secretKey == "" {
-		slog.Error("Required environment variables: RADOSGW_ENDPOINT, ACCESS_KEY, SECRET_KEY")
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
 		os.Exit(1)
 	}
+	if *printConfig {
+		out, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			slog.Error("Failed to render configuration", "error", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
 
-	store := getEnv("STORE", "us-east-1")
-	port := getEnv("METRICS_PORT", "9242")
-	insecure, _ := strconv.ParseBool(getEnv("INSECURE_SKIP_VERIFY", "false"))
+	// Create collector with logger; it is registered per scrape by the metrics handler
+	collector, err := NewRADOSGWCollector(cfg, logger)
+	if err != nil {
+		slog.Error("Failed to create collector", "error", err)
+		os.Exit(1)
+	}
+	elector, err := newLeaderElector(cfg, logger)
+	if err != nil {
+		slog.Error("Failed to configure leader election", "error", err)
+		os.Exit(1)
+	}
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
+	if elector != nil {
+		collector.leader = elector
+		go func() {
+			elector.run(electionCtx)
+			close(electionDone)
+		}()
+	} else {
+		close(electionDone)
+	}
+	prometheus.MustRegister(collector.durations, metricsUncompressedBytes, metricsSentBytes)
+	collector.Start()
 
-	// Create collector with logger
-	collector := NewRADOSGWCollector(endpoint, accessKey, secretKey, store, insecure, logger)
-	prometheus.MustRegister(collector)
+	var probes *probeHandler
+	if cfg.ConfigFile != "" {
+		modules, err := loadModules(cfg.ConfigFile, cfg)
+		if err != nil {
+			slog.Error("Invalid config file", "file", cfg.ConfigFile, "error", err)
+			os.Exit(1)
+		}
+		probes = newProbeHandler(modules, cfg, logger)
+	}
 
 	// HTTP server
+	landingPage, err := newLandingPage(cfg, collector.store)
+	if err != nil {
+		slog.Error("Failed to render landing page", "error", err)
+		os.Exit(1)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", landingPage)
+	mux.Handle(cfg.TelemetryPath, newMetricsHandler(collector, cfg))
+	mux.HandleFunc("/version", versionHandler)
+	mux.Handle("/debug/scrape", newScrapeReportHandler(collector))
+	if cfg.ScrapeInterval > 0 {
+		mux.Handle("/-/refresh", newRefreshHandler(collector))
+	}
+	if probes != nil {
+		mux.Handle("/probe", probes)
+	}
+	if cfg.ConfigToken != "" {
+		mux.Handle("/config", newConfigHandler(cfg))
+	}
+	var healthServer *http.Server
+	if cfg.HealthListenAddress != "" {
+		healthMux := http.NewServeMux()
+		registerHealth(healthMux, cfg, collector)
+		healthServer = &http.Server{Handler: healthMux}
+	} else {
+		registerHealth(mux, cfg, collector)
+	}
+	var handler http.Handler = mux
+	if len(cfg.WebCORSOrigins) > 0 {
+		handler = withCORS(handler, cfg)
+	}
+	if cfg.WebAccessLog {
+		handler = withAccessLog(handler, logger)
+	}
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: promhttp.Handler(),
+		Handler: handler,
+	}
+	webFlags := &web.FlagConfig{
+		WebConfigFile: &cfg.WebConfigFile,
+	}
+	if err := web.Validate(cfg.WebConfigFile); err != nil {
+		slog.Error("Invalid web config file", "file", cfg.WebConfigFile, "error", err)
+		os.Exit(1)
+	}
+	// The health listener answers during the warm-up, the metrics listeners only after it
+	if healthServer != nil {
+		healthListener, err := listen(cfg.HealthListenAddress)
+		if err != nil {
+			slog.Error("Failed to listen for health endpoints", "address", cfg.HealthListenAddress, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			slog.Info("Health listener started", "address", healthListener.Addr().String())
+			if err := healthServer.Serve(healthListener); err != nil && err != http.ErrServerClosed {
+				slog.Error("Health server failed", "error", err)
+			}
+		}()
+	}
+	if cfg.WarmupTimeout > 0 {
+		start := time.Now()
+		slog.Info("Warming up before serving metrics", "timeout", cfg.WarmupTimeout.String())
+		if collector.WarmUp(cfg.WarmupTimeout) {
+			slog.Info("Warm-up collection finished", "duration_sec", time.Since(start).Seconds())
+		} else {
+			slog.Warn("No successful collection during warm-up, serving anyway", "timeout", cfg.WarmupTimeout.String())
+		}
+	}
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		slog.Error("Failed to listen", "error", err)
+		os.Exit(1)
 	}
 
 	// Start server in background
 	go func() {
-		slog.Info("RADOSGW exporter started", "port", port, "endpoint", endpoint)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("RADOSGW exporter started", "version", version, "commit", commit, "listeners", len(listeners), "endpoint", cfg.Endpoint)
+		if err := web.ServeMultiple(listeners, server, webFlags, logger); err != nil && err != http.ErrServerClosed {
 			slog.Error("HTTP server failed", "error", err)
 		}
 	}()
 
+	// SIGUSR1 triggers an immediate background collection
+	if cfg.ScrapeInterval > 0 && len(refreshSignals) > 0 {
+		usr1 := make(chan os.Signal, 1)
+		signal.Notify(usr1, refreshSignals...)
+		go func() {
+			for range usr1 {
+				slog.Info("Refresh requested by signal")
+				collector.Refresh()
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	slog.Info("Shutdown signal received, initiating graceful shutdown...")
 
-	// Graceful shutdown with 10s timeout
+	// Graceful shutdown with 10s timeout: stop in-flight collections first
+	// so their handlers return promptly, then drain the HTTP server
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	if err := collector.Shutdown(ctx); err != nil {
+		slog.Warn("In-flight collections did not finish in time", "error", err)
+	}
+	// Hand leadership over to a standby right away
+	stopElection()
+	select {
+	case <-electionDone:
+	case <-ctx.Done():
+	}
+	if probes != nil {
+		if err := probes.Shutdown(ctx); err != nil {
+			slog.Warn("In-flight probes did not finish in time", "error", err)
+		}
+	}
+	if healthServer != nil {
+		if err := healthServer.Shutdown(ctx); err != nil {
+			slog.Warn("Health server shutdown failed", "error", err)
+		}
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		slog.Error("Graceful shutdown failed", "error", err)
 		os.Exit(1)
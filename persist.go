@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// saveSnapshot writes the metrics of snap to path in the text exposition format;
+// the file is replaced atomically and its modification time is the snapshot's
+func saveSnapshot(path string, snap *snapshot) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(replayCollector(snap.metrics)); err != nil {
+		return err
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), snap.at, snap.at); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadSnapshot reads a snapshot written by saveSnapshot
+func loadSnapshot(path string) (*snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &snapshot{at: info.ModTime()}
+	for _, mf := range families {
+		metrics, err := familyMetrics(mf)
+		if err != nil {
+			return nil, err
+		}
+		snap.metrics = append(snap.metrics, metrics...)
+	}
+	return snap, nil
+}
+
+// familyMetrics turns a parsed metric family back into constant metrics
+func familyMetrics(mf *dto.MetricFamily) ([]prometheus.Metric, error) {
+	metrics := make([]prometheus.Metric, 0, len(mf.GetMetric()))
+	for _, m := range mf.GetMetric() {
+		names := make([]string, 0, len(m.GetLabel()))
+		values := make([]string, 0, len(m.GetLabel()))
+		for _, l := range m.GetLabel() {
+			names = append(names, l.GetName())
+			values = append(values, l.GetValue())
+		}
+		desc := prometheus.NewDesc(mf.GetName(), mf.GetHelp(), names, nil)
+
+		var metric prometheus.Metric
+		var err error
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+		case dto.MetricType_GAUGE:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), values...)
+		case dto.MetricType_UNTYPED:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), values...)
+		case dto.MetricType_SUMMARY:
+			quantiles := make(map[float64]float64, len(m.GetSummary().GetQuantile()))
+			for _, q := range m.GetSummary().GetQuantile() {
+				quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			metric, err = prometheus.NewConstSummary(desc, m.GetSummary().GetSampleCount(), m.GetSummary().GetSampleSum(), quantiles, values...)
+		case dto.MetricType_HISTOGRAM:
+			buckets := make(map[float64]uint64, len(m.GetHistogram().GetBucket()))
+			for _, b := range m.GetHistogram().GetBucket() {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			metric, err = prometheus.NewConstHistogram(desc, m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum(), buckets, values...)
+		default:
+			err = fmt.Errorf("unsupported type %s", mf.GetType())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %w", mf.GetName(), err)
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// replayCollector is an unchecked collector sending a fixed set of metrics
+type replayCollector []prometheus.Metric
+
+// Describe implements Collector
+func (r replayCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements Collector
+func (r replayCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range r {
+		ch <- m
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.yaml.in/yaml/v2"
+)
+
+// probeFile — layout of RADOSGW_EXPORTER_CONFIG_FILE
+type probeFile struct {
+	Modules map[string]yaml.MapSlice `yaml:"modules"`
+}
+
+// loadModules reads the probe modules from path; each module starts from base
+// and overrides any of its settings by their YAML names
+func loadModules(path string, base Config) (map[string]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file probeFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	modules := make(map[string]Config, len(file.Modules))
+	for name, settings := range file.Modules {
+		out, err := yaml.Marshal(settings)
+		if err != nil {
+			return nil, err
+		}
+		cfg := base
+		if err := yaml.UnmarshalStrict(out, &cfg); err != nil {
+			return nil, fmt.Errorf("module %q in %s: %w", name, path, err)
+		}
+		// endpoint and endpoints describe one cluster; never fail over to the base one
+		switch {
+		case hasKey(settings, "endpoints") && len(cfg.Endpoints) > 0 && !hasKey(settings, "endpoint"):
+			cfg.Endpoint = cfg.Endpoints[0]
+		case !hasKey(settings, "endpoints"):
+			cfg.Endpoints = []string{cfg.Endpoint}
+		}
+		if _, err := enabledCollectors(cfg.Collectors); err != nil {
+			return nil, fmt.Errorf("module %q in %s: %w", name, path, err)
+		}
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("module %q in %s: %w", name, path, err)
+		}
+		modules[name] = cfg
+	}
+	return modules, nil
+}
+
+// hasKey reports whether a module sets the named option
+func hasKey(settings yaml.MapSlice, key string) bool {
+	for _, item := range settings {
+		if item.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// probeKey identifies the collector serving one module/target pair
+type probeKey struct {
+	module, target string
+}
+
+// probeEntry — the collector of a probed target and its use by scrapes
+type probeEntry struct {
+	// Closed once collector or err is set
+	ready     chan struct{}
+	collector *RADOSGWCollector
+	err       error
+
+	idleTimeout time.Duration
+	lastUsed    time.Time
+	active      int
+}
+
+// probeHandler serves /probe?target=...&module=..., collecting from the target
+// with the module's credentials and options
+type probeHandler struct {
+	modules       map[string]Config
+	logger        *slog.Logger
+	timeoutOffset time.Duration
+
+	// Collectors are kept per module/target so connections and coalescing are reused,
+	// and stopped once idle for the module's PROBE_IDLE_TIMEOUT
+	mu         sync.Mutex
+	collectors map[probeKey]*probeEntry
+	done       chan struct{}
+}
+
+func newProbeHandler(modules map[string]Config, cfg Config, logger *slog.Logger) *probeHandler {
+	h := &probeHandler{
+		modules:       modules,
+		logger:        logger,
+		timeoutOffset: cfg.ScrapeTimeoutOffset,
+		collectors:    make(map[probeKey]*probeEntry),
+		done:          make(chan struct{}),
+	}
+	every := cfg.ProbeIdleTimeout
+	for _, module := range modules {
+		every = min(every, module.ProbeIdleTimeout)
+	}
+	go h.expire(every / 2)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("module")
+	cfg, ok := h.modules[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", name), http.StatusBadRequest)
+		return
+	}
+	if target := query.Get("target"); target != "" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, fmt.Sprintf("Invalid target %q, expected an http(s) URL", target), http.StatusBadRequest)
+			return
+		}
+		// Requests are signed with the module's keys, so only known gateways get them
+		if !allowedTarget(cfg, u) {
+			http.Error(w, fmt.Sprintf("Target %q is not allowed for module %q", target, name), http.StatusForbidden)
+			return
+		}
+		cfg.Endpoint, cfg.Endpoints = target, []string{target}
+		cfg.DiscoveryDNSName, cfg.DiscoveryK8sService, cfg.DiscoveryCephConf = "", "", ""
+	}
+	if cfg.Endpoint == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	key := probeKey{module: name, target: cfg.Endpoint}
+	entry, err := h.acquire(r.Context(), key, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer h.release(entry)
+
+	ctx, cancel := scrapeContext(r.Context(), r, h.timeoutOffset)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(entry.collector.withContext(ctx), entry.collector.durations)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// allowedTarget reports whether the module may be probed against u: the target
+// is one of its endpoints or matches one of its PROBE_TARGETS
+func allowedTarget(cfg Config, u *url.URL) bool {
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, endpoint := range cfg.Endpoints {
+		if e, err := url.Parse(endpoint); err == nil && strings.ToLower(e.Scheme+"://"+e.Host) == origin {
+			return true
+		}
+	}
+	for _, pattern := range cfg.ProbeTargets {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimRight(pattern, "/")), origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire returns the entry for key, creating its collector on first use;
+// every successful acquire is paired with release
+func (h *probeHandler) acquire(ctx context.Context, key probeKey, cfg Config) (*probeEntry, error) {
+	h.mu.Lock()
+	entry, ok := h.collectors[key]
+	if !ok {
+		entry = &probeEntry{ready: make(chan struct{}), idleTimeout: cfg.ProbeIdleTimeout}
+		h.collectors[key] = entry
+	}
+	entry.active++
+	entry.lastUsed = time.Now()
+	h.mu.Unlock()
+
+	// Store autodetection and discovery call the target, so the collector is
+	// built outside the lock; concurrent probes of the same key wait for it
+	if !ok {
+		// A cache file holds a single collection, it cannot be shared by probed targets
+		cfg.CacheFile = ""
+		entry.collector, entry.err = NewRADOSGWCollector(cfg, h.logger.With("module", key.module, "target", key.target))
+		if entry.err == nil {
+			entry.collector.Start()
+		}
+		close(entry.ready)
+	}
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		h.release(entry)
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		h.mu.Lock()
+		// The next probe of the target retries
+		if h.collectors[key] == entry {
+			delete(h.collectors, key)
+		}
+		entry.active--
+		h.mu.Unlock()
+		return nil, entry.err
+	}
+	return entry, nil
+}
+
+// release marks a scrape of the entry done
+func (h *probeHandler) release(entry *probeEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry.active--
+	entry.lastUsed = time.Now()
+}
+
+// expire stops, every period, the collectors no probe has used for their idle timeout
+func (h *probeHandler) expire(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+		idle := make(map[probeKey]*probeEntry)
+		h.mu.Lock()
+		for key, entry := range h.collectors {
+			if entry.active == 0 && time.Since(entry.lastUsed) >= entry.idleTimeout {
+				delete(h.collectors, key)
+				idle[key] = entry
+			}
+		}
+		h.mu.Unlock()
+		for key, entry := range idle {
+			h.logger.Debug("Stopping idle probe collector", "module", key.module, "target", key.target)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := entry.collector.Shutdown(ctx); err != nil {
+				h.logger.Warn("Idle probe collector did not stop in time", "module", key.module, "target", key.target, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Shutdown stops the collections of every probed target
+func (h *probeHandler) Shutdown(ctx context.Context) error {
+	close(h.done)
+	h.mu.Lock()
+	entries := make([]*probeEntry, 0, len(h.collectors))
+	for _, entry := range h.collectors {
+		entries = append(entries, entry)
+	}
+	h.mu.Unlock()
+	var errs []error
+	for _, entry := range entries {
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if entry.collector != nil {
+			errs = append(errs, entry.collector.Shutdown(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// refreshSignals is empty on platforms without SIGUSR1; use POST /-/refresh instead
+var refreshSignals []os.Signal
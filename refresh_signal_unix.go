@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// refreshSignals trigger an immediate background collection
+var refreshSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxReportErrors — errors kept verbatim in a scrape report; the rest are only counted
+const maxReportErrors = 50
+
+// scrapeReport describes the internals of one collection, served on /debug/scrape
+type scrapeReport struct {
+	mu sync.Mutex
+
+	Start           time.Time      `json:"start"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Up              bool           `json:"up"`
+	Phases          []phaseTiming  `json:"phases"`
+	UsageEntries    int            `json:"usage_entries"`
+	Users           int            `json:"users"`
+	Buckets         int            `json:"buckets"`
+	APICalls        int64          `json:"api_calls"`
+	Endpoints       map[string]int `json:"endpoints"`
+	ErrorCount      int            `json:"error_count"`
+	Errors          []string       `json:"errors"`
+
+	// Incremented by adminTransport for every request sent on behalf of this collection
+	apiCalls atomic.Int64
+}
+
+// phaseTiming — wall time spent in one step of a collection
+type phaseTiming struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+func newScrapeReport() *scrapeReport {
+	return &scrapeReport{Start: time.Now(), Phases: []phaseTiming{}, Endpoints: map[string]int{}, Errors: []string{}}
+}
+
+// phase records the time elapsed since start under name
+func (r *scrapeReport) phase(name string, start time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Phases = append(r.Phases, phaseTiming{Name: name, DurationSeconds: time.Since(start).Seconds()})
+}
+
+// count adds n to the counter selected by field
+func (r *scrapeReport) count(field *int, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*field += n
+}
+
+// addError records a collection error
+func (r *scrapeReport) addError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ErrorCount++
+	if len(r.Errors) < maxReportErrors {
+		r.Errors = append(r.Errors, err.Error())
+	}
+}
+
+// finish stamps the totals once the collection has returned
+func (r *scrapeReport) finish(up bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.DurationSeconds = time.Since(r.Start).Seconds()
+	r.Up = up
+	r.APICalls = r.apiCalls.Load()
+}
+
+// scrapeReportKey — context key carrying the report of the running collection
+type scrapeReportKey struct{}
+
+func withScrapeReport(ctx context.Context, r *scrapeReport) context.Context {
+	return context.WithValue(ctx, scrapeReportKey{}, r)
+}
+
+// countAPICall attributes one admin API request to the collection running in ctx
+func countAPICall(ctx context.Context) {
+	if r, ok := ctx.Value(scrapeReportKey{}).(*scrapeReport); ok {
+		r.apiCalls.Add(1)
+	}
+}
+
+// servedBy attributes one successful admin API response to endpoint
+func servedBy(ctx context.Context, endpoint string) {
+	if r, ok := ctx.Value(scrapeReportKey{}).(*scrapeReport); ok {
+		r.mu.Lock()
+		r.Endpoints[endpoint]++
+		r.mu.Unlock()
+	}
+}
+
+// newScrapeReportHandler serves the report of the most recent collection as JSON
+func newScrapeReportHandler(c *RADOSGWCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.lastReport.Load()
+		if report == nil {
+			http.Error(w, "No collection has completed yet", http.StatusNotFound)
+			return
+		}
+		report.mu.Lock()
+		defer report.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// serviceMap — the part of `ceph service dump` describing RGW daemons
+type serviceMap struct {
+	Services struct {
+		RGW struct {
+			Daemons map[string]json.RawMessage `json:"daemons"`
+		} `json:"rgw"`
+	} `json:"services"`
+}
+
+// serviceDaemon — one entry of the RGW daemon map; the map also holds a "summary" string
+type serviceDaemon struct {
+	Addr     string            `json:"addr"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// parseServiceMap returns ip:port of every RGW daemon in a `ceph service dump`,
+// using the TLS frontend ports when tls is set and, when zone is not empty,
+// only daemons of that zone
+func parseServiceMap(data []byte, tls bool, zone string) ([]string, error) {
+	var m serviceMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode service map: %w", err)
+	}
+
+	names := make([]string, 0, len(m.Services.RGW.Daemons))
+	for name := range m.Services.RGW.Daemons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []string
+	for _, name := range names {
+		var daemon serviceDaemon
+		if err := json.Unmarshal(m.Services.RGW.Daemons[name], &daemon); err != nil || daemon.Addr == "" {
+			continue
+		}
+		if zone != "" && daemon.Metadata["zone_name"] != zone {
+			continue
+		}
+		// addr is ip:port/nonce of the daemon's messenger, only the IP is useful
+		addr, _, _ := strings.Cut(daemon.Addr, "/")
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		for key, frontend := range daemon.Metadata {
+			if !strings.HasPrefix(key, "frontend_config#") {
+				continue
+			}
+			for _, port := range frontendPorts(frontend, tls) {
+				out = append(out, net.JoinHostPort(host, port))
+			}
+		}
+	}
+	return out, nil
+}
+
+// frontendPorts extracts the plain or TLS ports of one rgw_frontends entry, e.g.
+// "beast port=8080 ssl_port=8443" or "civetweb port=80+443s"
+func frontendPorts(frontend string, tls bool) []string {
+	var plain, secure []string
+	for _, option := range strings.Fields(frontend) {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "port":
+			for _, p := range strings.Split(value, "+") {
+				if s, ok := strings.CutSuffix(p, "s"); ok {
+					secure = append(secure, s)
+				} else {
+					plain = append(plain, p)
+				}
+			}
+		case "ssl_port":
+			secure = append(secure, value)
+		case "endpoint", "ssl_endpoint":
+			port := "80"
+			if key == "ssl_endpoint" {
+				port = "443"
+			}
+			if _, p, err := net.SplitHostPort(value); err == nil {
+				port = p
+			}
+			if key == "ssl_endpoint" {
+				secure = append(secure, port)
+			} else {
+				plain = append(plain, port)
+			}
+		}
+	}
+	if len(plain) == 0 && len(secure) == 0 {
+		// beast and civetweb listen on port 80 by default
+		plain = []string{"80"}
+	}
+
+	ports := plain
+	if tls {
+		ports = secure
+	}
+	valid := ports[:0]
+	for _, p := range ports {
+		if _, err := strconv.Atoi(p); err == nil {
+			valid = append(valid, p)
+		}
+	}
+	return valid
+}
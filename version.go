@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// Build information, injected at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// buildInfo describes the running binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func currentBuildInfo() buildInfo {
+	return buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// String renders the build information on one line for --version
+func (b buildInfo) String() string {
+	return fmt.Sprintf("radosgw_exporter, version %s (commit: %s, build date: %s, go: %s)", b.Version, b.Commit, b.BuildDate, b.GoVersion)
+}
+
+// versionHandler serves the build information as JSON
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentBuildInfo())
+}
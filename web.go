@@ -0,0 +1,513 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+	"go.yaml.in/yaml/v2"
+	"golang.org/x/time/rate"
+)
+
+// openListeners returns the systemd-activated sockets when socket activation is
+// enabled, otherwise opens every configured listen address
+func openListeners(cfg Config) ([]net.Listener, error) {
+	if cfg.WebSystemdSocket {
+		listeners, err := activation.Listeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(listeners) == 0 {
+			return nil, errors.New("no systemd socket activation file descriptors found")
+		}
+		return listeners, nil
+	}
+
+	listeners := make([]net.Listener, 0, len(cfg.ListenAddresses))
+	for _, address := range cfg.ListenAddresses {
+		listener, err := listen(address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("listen on %s: %w", address, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listen opens a listener for address: host:port (dual-stack TCP),
+// tcp4://host:port or tcp6://host:port (single IP family) or unix:///path
+func listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		// Remove a stale socket left behind by an unclean exit
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	for _, network := range []string{"tcp4", "tcp6"} {
+		if hostport, ok := strings.CutPrefix(address, network+"://"); ok {
+			return net.Listen(network, hostport)
+		}
+	}
+	return net.Listen("tcp", address)
+}
+
+// metricsHandler serves metrics, bounding each collection by the scrape timeout
+// Prometheus announces in X-Prometheus-Scrape-Timeout-Seconds minus an offset
+type metricsHandler struct {
+	collector     *RADOSGWCollector
+	timeoutOffset time.Duration
+	opts          promhttp.HandlerOpts
+	compression   string
+
+	// Per-client request rate limit; nil when disabled
+	limiter *clientLimiter
+
+	// Bounds concurrent scrapes; promhttp's own limit is per handler instance,
+	// and a handler is built for every request
+	inflight chan struct{}
+}
+
+// Exposition payload sizes before and after response compression, registered
+// once by main as every metrics handler adds to them
+var (
+	metricsUncompressedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "radosgw_exporter_metrics_response_uncompressed_bytes_total",
+		Help: "Total size of /metrics response bodies before compression",
+	})
+	metricsSentBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "radosgw_exporter_metrics_response_bytes_total",
+		Help: "Total size of /metrics response bodies as sent, after compression",
+	})
+)
+
+// scrapeContext bounds ctx by the scraper's X-Prometheus-Scrape-Timeout-Seconds
+// minus offset, so a collection returns before Prometheus gives up on it
+func scrapeContext(ctx context.Context, r *http.Request, offset time.Duration) (context.Context, context.CancelFunc) {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	seconds, err := strconv.ParseFloat(v, 64)
+	if v == "" || err != nil || seconds <= 0 {
+		return ctx, func() {}
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - offset
+	if timeout <= 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// compressionModes — accepted RADOSGW_EXPORTER_WEB_COMPRESSION values
+var compressionModes = []string{"auto", "gzip", "none"}
+
+// errorHandling maps RADOSGW_EXPORTER_WEB_ERROR_HANDLING values to promhttp modes
+var errorHandling = map[string]promhttp.HandlerErrorHandling{
+	"http":     promhttp.HTTPErrorOnError,
+	"continue": promhttp.ContinueOnError,
+	"panic":    promhttp.PanicOnError,
+}
+
+func newMetricsHandler(collector *RADOSGWCollector, cfg Config) http.Handler {
+	h := &metricsHandler{
+		collector:     collector,
+		timeoutOffset: cfg.ScrapeTimeoutOffset,
+		opts: promhttp.HandlerOpts{
+			ErrorHandling: errorHandling[cfg.WebErrorHandling],
+			Timeout:       cfg.WebTimeout,
+			// Compression is done by ServeHTTP so both sizes can be measured
+			DisableCompression: true,
+		},
+		compression: cfg.WebCompression,
+	}
+	if cfg.WebMaxRequests > 0 {
+		h.inflight = make(chan struct{}, cfg.WebMaxRequests)
+	}
+	if cfg.WebRateLimit > 0 {
+		h.limiter = newClientLimiter(rate.Limit(cfg.WebRateLimit), cfg.WebRateBurst)
+	}
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, h)
+}
+
+// ServeHTTP implements http.Handler
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.limiter != nil && !h.limiter.allow(clientAddr(r)) {
+		w.Header().Set("Retry-After", strconv.Itoa(h.limiter.retryAfter()))
+		http.Error(w, "Scrape rate limit exceeded, try again later.", http.StatusTooManyRequests)
+		return
+	}
+	if h.inflight != nil {
+		select {
+		case h.inflight <- struct{}{}:
+			defer func() { <-h.inflight }()
+		default:
+			http.Error(w, fmt.Sprintf("Limit of concurrent requests reached (%d), try again later.", cap(h.inflight)), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	if h.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.opts.Timeout)
+		defer cancel()
+	}
+	ctx, cancel := scrapeContext(ctx, r, h.timeoutOffset)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(h.collector.withContext(ctx))
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+
+	cw := &compressWriter{ResponseWriter: w, sent: &countingWriter{w: w}}
+	switch h.compression {
+	case "gzip":
+		cw.gzip = true
+	case "auto":
+		cw.gzip = acceptsGzip(r)
+	}
+	promhttp.HandlerFor(gatherers, h.opts).ServeHTTP(cw, r)
+	cw.Close()
+	metricsUncompressedBytes.Add(float64(cw.n))
+	metricsSentBytes.Add(float64(cw.sent.n))
+}
+
+// clientLimiterIdle — how long a client's limiter is kept after its last request
+const clientLimiterIdle = 10 * time.Minute
+
+// clientLimiter keeps a token bucket per client address
+type clientLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*clientRate
+	lastSweep time.Time
+}
+
+type clientRate struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiter(limit rate.Limit, burst int) *clientLimiter {
+	return &clientLimiter{
+		limit:     limit,
+		burst:     burst,
+		clients:   make(map[string]*clientRate),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow reports whether client may scrape now, forgetting idle clients on the way
+func (l *clientLimiter) allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > clientLimiterIdle {
+		for addr, c := range l.clients {
+			if now.Sub(c.lastSeen) > clientLimiterIdle {
+				delete(l.clients, addr)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientRate{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = c
+	}
+	c.lastSeen = now
+	return c.limiter.AllowN(now, 1)
+}
+
+// retryAfter returns the Retry-After value in whole seconds for one token
+func (l *clientLimiter) retryAfter() int {
+	return max(int(math.Ceil(1/float64(l.limit))), 1)
+}
+
+// clientAddr identifies the scraping client by its remote IP
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// acceptsGzip reports whether the client listed gzip in Accept-Encoding
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// countingWriter counts bytes passed through to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// compressWriter gzips successful responses and counts the uncompressed body size;
+// error responses are sent as is
+type compressWriter struct {
+	http.ResponseWriter
+	gzip        bool
+	sent        *countingWriter
+	out         io.Writer
+	gz          *gzip.Writer
+	wroteHeader bool
+	n           int64
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.out = c.sent
+	if c.gzip && status == http.StatusOK {
+		c.Header().Set("Content-Encoding", "gzip")
+		c.Header().Del("Content-Length")
+		c.gz = gzip.NewWriter(c.sent)
+		c.out = c.gz
+	}
+	c.Header().Add("Vary", "Accept-Encoding")
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	n, err := c.out.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Close flushes the gzip stream, if any
+func (c *compressWriter) Close() error {
+	if c.gz == nil {
+		return nil
+	}
+	return c.gz.Close()
+}
+
+// newLandingPage renders the index page with links and the configured target
+func newLandingPage(cfg Config, store string) (http.Handler, error) {
+	endpoint := cfg.Endpoint
+	if u, err := url.Parse(endpoint); err == nil {
+		endpoint = u.Redacted()
+	}
+
+	links := []web.LandingLinks{
+		{Address: cfg.TelemetryPath, Text: "Metrics"},
+		{Address: "/version", Text: "Version", Description: "build information as JSON"},
+		{Address: "/debug/scrape", Text: "Last scrape", Description: "timings, counts and errors of the most recent collection as JSON"},
+	}
+	if cfg.HealthListenAddress == "" {
+		links = append(links,
+			web.LandingLinks{Address: "/healthz", Text: "Health", Description: "liveness probe"},
+			web.LandingLinks{Address: "/readyz", Text: "Readiness", Description: "200 after the first successful collection"},
+		)
+	}
+	if cfg.ScrapeInterval > 0 {
+		links = append(links, web.LandingLinks{Address: "/-/refresh", Text: "Refresh", Description: "POST to collect right away instead of waiting for the interval"})
+	}
+	if cfg.ConfigToken != "" {
+		links = append(links, web.LandingLinks{Address: "/config", Text: "Config", Description: "effective configuration, bearer token required"})
+	}
+
+	return web.NewLandingPage(web.LandingConfig{
+		Name:        "RADOSGW Exporter",
+		Version:     version,
+		Description: "Prometheus exporter for Ceph RADOS Gateway usage, quota and bucket statistics",
+		Links:       links,
+		ExtraHTML:   fmt.Sprintf("<div>Target: <code>%s</code>, store: <code>%s</code></div>", html.EscapeString(endpoint), html.EscapeString(store)),
+		Profiling:   strconv.FormatBool(cfg.WebEnablePprof && cfg.HealthListenAddress == ""),
+	})
+}
+
+// registerHealth mounts the probe endpoints and, when enabled, pprof on mux
+func registerHealth(mux *http.ServeMux, cfg Config, collector *RADOSGWCollector) {
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.Handle("/readyz", newReadyzHandler(collector))
+	if cfg.WebEnablePprof {
+		registerPprof(mux)
+	}
+}
+
+// registerPprof mounts the net/http/pprof handlers on mux
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// newConfigHandler serves the redacted effective configuration to bearer-token holders
+func newConfigHandler(cfg Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ConfigToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		out, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		w.Write(out)
+	})
+}
+
+// newRefreshHandler triggers an immediate background collection on POST; the
+// response does not wait for the collection to finish
+func newRefreshHandler(c *RADOSGWCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed, use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		c.Refresh()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Refresh scheduled"))
+	})
+}
+
+// healthzHandler reports that the process is alive without touching RGW
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// newReadyzHandler reports ready once the collector has talked to RGW successfully
+func newReadyzHandler(c *RADOSGWCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.Ready() {
+			http.Error(w, "Not ready: no successful collection yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("OK"))
+	})
+}
+
+// withCORS adds CORS headers for allowed origins on every path except the
+// telemetry path and answers preflight requests
+func withCORS(next http.Handler, cfg Config) http.Handler {
+	methods := strings.Join(cfg.WebCORSMethods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || r.URL.Path == cfg.TelemetryPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		switch {
+		case slices.Contains(cfg.WebCORSOrigins, "*"):
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case slices.Contains(cfg.WebCORSOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder captures the status code and body size written by a handler;
+// the status stays 200 when the handler writes neither a header nor a body
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	// Like net/http, only the first status counts
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// withAccessLog logs every request handled by next
+func withAccessLog(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logger.Info("HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_sec", time.Since(start).Seconds(),
+			"remote_addr", r.RemoteAddr,
+			"scrape_timeout", r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"),
+		)
+	})
+}